@@ -16,6 +16,9 @@ LineBytes() again, as the content of the internal buffer–and thus slices
 returned by LineBytes()–may be overwritten. If you need to retain the line
 data, make a copy of it or use Line().
 
+With Go 1.23 or newer, lines may also be iterated over using Scanner.All() or
+Scanner.AllBytes(); errors other than io.EOF can be checked with Scanner.Err().
+
 Example using it:
 
 	input := "Line1\nLine2\nLine3"
@@ -65,13 +68,21 @@ Using it to efficiently scan a file, finding last occurrence of a string ("error
 			break
 		}
 	}
+
+Opening the file and creating the Scanner positioned at its end can also be
+done with NewFromFile(); the file is closed by Scanner.Close().
 */
 package backscanner
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"hash"
 	"io"
+	"time"
 )
 
 const (
@@ -80,32 +91,416 @@ const (
 
 	// DefaultMaxBufferSize is the default value for the MaxBufferSize option
 	DefaultMaxBufferSize = 1 << 20 // 1 MB
+
+	// DefaultSeparator is the default value for the Separator option
+	DefaultSeparator = '\n'
 )
 
+// unicodeLineBreaks holds the Unicode line terminators that are recognized
+// if Options.UnicodeLineBreaks is set.
+var unicodeLineBreaks = []string{
+	"\u2028", // LINE SEPARATOR
+	"\u2029", // PARAGRAPH SEPARATOR
+	"\u0085", // NEXT LINE
+}
+
 var (
-	// ErrLongLine indicates that the line is longer than the internal buffer size
+	// ErrLongLine indicates that the line is longer than the internal buffer size.
+	// The actual error returned is a *LongLineError, use errors.Is() to check it.
 	ErrLongLine = errors.New("line too long")
+
+	// ErrPosBeyondEnd indicates that the starting position is beyond the end of the input
+	ErrPosBeyondEnd = errors.New("position beyond end of input")
+
+	// ErrNilReader indicates that the input of the Scanner is nil
+	ErrNilReader = errors.New("nil reader")
+
+	// ErrInvalidUnread indicates that UnreadLine() was called not right after
+	// a line was returned
+	ErrInvalidUnread = errors.New("invalid use of UnreadLine")
+
+	// ErrInvalidMark indicates that Rewind() was called with a Mark whose
+	// data is no longer buffered
+	ErrInvalidMark = errors.New("invalid mark")
+
+	// ErrMaxLines indicates that the number of returned lines reached Options.MaxLines
+	ErrMaxLines = errors.New("max lines reached")
+
+	// ErrReadBudgetExceeded indicates that reading more of the input would
+	// exceed Options.MaxBytesRead
+	ErrReadBudgetExceeded = errors.New("read budget exceeded")
 )
 
+// LongLineError is the error returned if a line does not fit into the internal
+// buffer, or it is longer than MaxLineSize. It matches ErrLongLine when checked
+// with errors.Is().
+type LongLineError struct {
+	// MaxBufferSize is the max buffer size in effect.
+	MaxBufferSize int
+
+	// MaxLineSize is the max line size in effect.
+	MaxLineSize int
+
+	// AttemptedSize is the buffer size that would have been needed to read more
+	// of the line (the line may be even longer), or the known size of the line
+	// if it exceeds MaxLineSize.
+	AttemptedSize int
+
+	// Pos is the position of the known part of the line:
+	// the line starts at or before Pos.
+	Pos int64
+}
+
+// Error implements error.
+func (e *LongLineError) Error() string {
+	limit, max := "buffer", e.MaxBufferSize
+	if e.MaxLineSize < e.MaxBufferSize && e.AttemptedSize > e.MaxLineSize {
+		limit, max = "line", e.MaxLineSize
+	}
+	return fmt.Sprintf("line too long: line at or before position %d exceeds max %s size of %d bytes (attempted %d bytes)",
+		e.Pos, limit, max, e.AttemptedSize)
+}
+
+// Is tells if target is ErrLongLine, so errors.Is(err, ErrLongLine) reports true.
+func (e *LongLineError) Is(target error) bool {
+	return target == ErrLongLine
+}
+
+// ReadError is the error returned if reading the input fails. io.EOF reported
+// by the input (even if wrapped) is not a read failure, the end of the input
+// is always reported with the bare io.EOF.
+type ReadError struct {
+	// Pos is the position of the failed read.
+	Pos int64
+
+	// Err is the error returned by the input.
+	Err error
+}
+
+// Error implements error.
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("read failed at position %d: %v", e.Pos, e.Err)
+}
+
+// Unwrap returns the error returned by the input.
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
 // Scanner is the back-scanner implementation.
 type Scanner struct {
 	r   io.ReaderAt // r is the input to read from
-	pos int         // pos is the position of the last read chunk
+	pos int64       // pos is the position of the last read chunk
 	o   Options     // o is the Options in effect (options to work with)
 
-	err  error  // err is the encountered error (if any)
-	buf  []byte // buf stores the read but not yet returned data
-	buf2 []byte // buf2 stores the last buffer to be reused
+	split ReverseSplitFunc // split is the custom split function (if any)
+
+	err error  // err is the encountered error (if any)
+	buf []byte // buf stores the read but not yet returned data, it's a slice of arr
+	arr []byte // arr is the backing array of buf, data is kept at its end
+
+	sep       []byte   // sep is the encoded Separator if Encoding is not UTF8
+	breaks    [][]byte // breaks holds the encoded Unicode line breaks to recognize
+	maxSepLen int      // maxSepLen is the max length of the recognized separators
+	oerr      error    // oerr is the error of invalid Options, reported when scanning
+	dec       []byte   // dec stores the last decoded line
+	tbuf      []byte   // tbuf stores the last line transformed by Decoder
+	xbuf      []byte   // xbuf stores the copy of the last line passed to Transform
+
+	tail      int    // tail is the length of the kept terminator at the end of buf
+	clean     int    // clean is the number of bytes at the end of buf known to have no separator
+	chunk     int    // chunk is the size of the next chunk if AdaptiveChunk or GrowthChunks is set (0 means ChunkSize)
+	last      int    // last is the number of bytes consumed from buf by the last line
+	ltail     int    // ltail is the value of tail before the last line
+	canUnread bool   // canUnread tells if the last line can be unread
+	detected  bool   // detected tells if the line ending is detected (if AutoDetectLineEnding is set)
+	lpos      int64  // lpos is the position of the last returned line
+	minKnown  bool   // minKnown tells if minTerm is known
+	minTerm   bool   // minTerm tells if there is a line terminator right before MinPos
+	term      []byte // term is the terminator after the last returned line
+	nterm     []byte // nterm is the terminator before the last returned line
+	cr        []byte // cr is the terminal '\r' dropped from the last returned line
+	tterm     []byte // tterm is the full terminator returned by LineBytesTerm()
+	blank     bool   // blank tells if the last returned line was empty
+	lblank    bool   // lblank is the value of blank before the last line
+	counted   bool   // counted tells if the next line is already written to Hash and counted in lens (it was unread)
+	prev      []byte // prev is a copy of the last returned line if Unique is set
+	lprev     []byte // lprev is the value of prev before the last line
+	hasPrev   bool   // hasPrev tells if prev is valid
+	valid     bool   // valid tells if the last returned line is valid JSON (if ValidateJSON is set)
+	lhasPrev  bool   // lhasPrev is the value of hasPrev before the last line
+	token     []byte // token is the last line scanned by Scan()
+	lines     int    // lines is the number of lines returned so far
+	end       int64  // end is the end position of the last returned line
+
+	start         int64 // start is the starting position
+	trailingKnown bool  // trailingKnown tells if trailing is determined
+	trailing      bool  // trailing tells if the input ends with a terminator at start
+
+	pf *prefetcher // pf prefetches chunks if Prefetch is set
+
+	mem []byte // mem is the content of an in-memory input, used instead of reading it
+
+	gen int // gen is incremented when the input is set, it invalidates marks
+
+	starts []int64 // starts holds the start positions of the parts of NewMulti()
+	part   int     // part is the index of the part being scanned
+
+	stats Stats       // stats holds the statistics of the Scanner
+	lens  lengthStats // lens holds the length statistics of the returned lines
 }
 
 // Options contains parameters that influence the internal working of the Scanner.
 type Options struct {
 	// ChunkSize specifies the size of the chunk that is read at once from the input.
+	// It must not exceed MaxBufferSize (else it is replaced with MaxBufferSize).
 	ChunkSize int
 
 	// MaxBufferSize limits the maximum size of the buffer used internally.
 	// This also limits the max line size.
 	MaxBufferSize int
+
+	// Separator is the byte that separates lines. The default is '\n'.
+	// A terminal '\r' is only dropped from lines if Separator is '\n'.
+	Separator byte
+
+	// Delimiter is a byte sequence that separates lines, e.g. "\r\n" or "<END>".
+	// If not empty, it takes precedence over Separator, and no terminal '\r'
+	// is dropped from lines.
+	// If occurrences of the delimiter overlap, the last one wins.
+	Delimiter []byte
+
+	// KeepTerminator tells if line terminators are to be kept in returned lines
+	// (including a terminal '\r' which is dropped otherwise). Returned positions
+	// still point to the first byte of lines.
+	KeepTerminator bool
+
+	// KeepCR tells if a terminal '\r' is to be kept in returned lines.
+	// By default it is dropped if Separator is '\n'.
+	KeepCR bool
+
+	// UnicodeLineBreaks tells if U+2028 (LINE SEPARATOR), U+2029 (PARAGRAPH SEPARATOR)
+	// and U+0085 (NEXT LINE) are also to be treated as line terminators,
+	// in addition to Separator (or Delimiter).
+	UnicodeLineBreaks bool
+
+	// Encoding is the character encoding of the input. The default is UTF8.
+	//
+	// If it is UTF16LE or UTF16BE, lines are split on the encoded form of
+	// Separator (Delimiter is used as-is), and returned lines are decoded to
+	// UTF-8. A byte order mark at position 0 is stripped. Returned positions
+	// are byte-positions in the (encoded) input.
+	Encoding Encoding
+
+	// Decoder is an optional Transformer (e.g. a charmap decoder of the
+	// golang.org/x/text module) that is applied to each line before it is
+	// returned (after decoding the Encoding).
+	//
+	// Most decoders are stateful and are designed to process input in forward
+	// order, so the Decoder is applied to each line separately after splitting,
+	// and it is reset before each line. Lines are split before decoding, so the
+	// line terminators must be recognizable in the undecoded input.
+	Decoder Transformer
+
+	// StripBOM tells if a UTF-8 byte order mark is to be stripped from the line
+	// at position 0 (the first line of the input).
+	// If Encoding is not UTF8, the byte order mark is always stripped.
+	StripBOM bool
+
+	// ValidatePos tells if the starting position is to be validated against
+	// the size of the input: if the size can be determined (the input has a
+	// Size() int64 method like bytes.Reader, strings.Reader and io.SectionReader,
+	// or it implements io.Seeker), a position beyond the end is clamped to the
+	// end of the input.
+	//
+	// Without this, a position beyond the end of the input results in
+	// ErrPosBeyondEnd when reading.
+	ValidatePos bool
+
+	// SplitMode tells what units the input is split into. The default is Lines.
+	SplitMode SplitMode
+
+	// AdaptiveChunk tells if the chunk size is to be doubled (up to MaxBufferSize)
+	// each time a chunk is read without finding the end of the line, which
+	// reduces the number of reads for long lines. It is reset to ChunkSize
+	// after a line is returned.
+	AdaptiveChunk bool
+
+	// GrowthChunks is the number of chunks to read at once each time a chunk
+	// is read without finding the end of the line (up to MaxBufferSize), which
+	// reduces the number of reads for long lines. The first read for a line
+	// reads ChunkSize bytes. It is ignored if AdaptiveChunk is set.
+	// Default value is 1 (a single chunk is read each time).
+	GrowthChunks int
+
+	// BufferPool is an optional pool to obtain internal buffers from.
+	// Buffers are returned to the pool when the Scanner is closed or reset,
+	// or when they are replaced by larger ones.
+	BufferPool BufferPool
+
+	// Prefetch tells if the chunk preceding the last read one is to be read
+	// ahead in a goroutine, while the returned lines are processed. This hides
+	// the latency of slow inputs. Reads are not concurrent: the input is only
+	// read by the goroutine while a chunk is being prefetched.
+	//
+	// The goroutine is started with the first read, and it is stopped by
+	// Close() or Reset(), so Close() must be called when the Scanner is no
+	// longer used, even if scanning is stopped before reaching the start.
+	Prefetch bool
+
+	// MaxLines limits the number of lines returned. After MaxLines lines are
+	// returned, subsequent calls report ErrMaxLines. 0 means no limit.
+	// The count is reset by Reset().
+	MaxLines int
+
+	// MinPos is the position where scanning stops as if it was the start of
+	// the input: after returning the line starting at MinPos, subsequent calls
+	// report io.EOF. This can be used to only scan a window of the input,
+	// e.g. its last 1 MB.
+	//
+	// The line starting at MinPos may be partial: it is cut at MinPos if the
+	// input has no line terminator right before MinPos (see Scanner.Truncated()).
+	MinPos int
+
+	// ReadRetry is an optional function that is called when reading the input
+	// fails with an error other than io.EOF, with the error and the number of
+	// the retry attempt (starting at 1). If it returns true, the read is retried,
+	// else scanning stops with the error. This can be used to survive transient
+	// errors of network-backed inputs; to limit retries, return false after
+	// a number of attempts.
+	ReadRetry func(err error, attempt int) bool
+
+	// ReadTimeout is the max duration of a ReadAt call of the input, after
+	// which ErrReadTimeout is reported (wrapped in a *ReadError, so it may be
+	// retried using ReadRetry). 0 means no timeout.
+	//
+	// Since a ReadAt call cannot be cancelled, it is made in a new goroutine
+	// (reading into a newly allocated buffer), and if it times out, the
+	// goroutine is left running (leaked) until the call returns. This is meant
+	// to guard against occasional slow reads (e.g. of network-backed inputs),
+	// not to cancel reads that never return.
+	ReadTimeout time.Duration
+
+	// OnProgress is an optional function that is called after each chunk read
+	// from the input, with the new position (see Scanner.Pos()). Since positions
+	// decrease, the progress of scanning the entire input of a given size is
+	// 1 - pos/size.
+	OnProgress func(pos int)
+
+	// OnRead is an optional function that is called after each chunk read
+	// from the input, with the offset and size of the read data, the duration
+	// and the error of the read (io.EOF may be reported even if the chunk is
+	// read fully, failures are reported as *ReadError). The duration includes retries (see
+	// ReadRetry); if Prefetch is set, it is the time spent waiting for the
+	// prefetched data.
+	OnRead func(offset int64, n int, dur time.Duration, err error)
+
+	// SkipEmpty tells if empty lines are to be skipped: only non-empty lines
+	// are returned (and counted by LineNumber()).
+	SkipEmpty bool
+
+	// TrimSpace tells if leading and trailing white space is to be trimmed from
+	// lines (using bytes.TrimSpace()). Returned positions still point to the
+	// first byte of the untrimmed lines. If SkipEmpty is also set, lines
+	// containing only white space are skipped.
+	TrimSpace bool
+
+	// ShrinkBuffer tells if the internal buffer is to be shrunk when it is much
+	// larger than needed (e.g. after a very long line is returned), so memory is
+	// not retained for the rest of the scan.
+	ShrinkBuffer bool
+
+	// InitialBufferSize is the size of the internal buffer to allocate
+	// up front (capped at MaxBufferSize). It is also the size the buffer
+	// is not shrunk below if ShrinkBuffer is set. It is useful if the typical
+	// line length is known to be larger than ChunkSize, to avoid regrowing
+	// the buffer.
+	InitialBufferSize int
+
+	// MaxLineSize limits the max line size independently of MaxBufferSize
+	// (which also limits it): longer lines result in an error matching
+	// ErrLongLine. The size is measured in the input, excluding the terminator
+	// (but including a terminal '\r'). The default is MaxBufferSize. It is only enforced
+	// in Lines SplitMode without a custom split function.
+	MaxLineSize int
+
+	// CollapseBlankLines tells if runs of consecutive empty lines are to be
+	// returned as a single empty line: the first one of the run that is
+	// returned (the last one in the input). If TrimSpace is set, lines
+	// containing only white space are also empty.
+	CollapseBlankLines bool
+
+	// Hash, if set, is written with the bytes of each returned line, so a digest
+	// of the scanned content can be obtained using its Sum() method.
+	// Since the input is scanned backward, lines are written in reverse order
+	// (the last line of the input first). Terminators are only included if
+	// KeepTerminator is set. A line that is unread (or peeked) is only written
+	// once.
+	Hash hash.Hash
+
+	// Binary tells if the input is to be treated as raw bytes: lines are only
+	// split on Separator (or Delimiter), and they are returned as they are in
+	// the input, possibly containing arbitrary bytes (including NUL bytes and
+	// invalid UTF-8 sequences). No terminal '\r' is dropped, and the options
+	// transforming lines or splitting them otherwise are ignored: Encoding,
+	// Decoder, StripBOM, TrimSpace, UnicodeLineBreaks and SplitMode.
+	Binary bool
+
+	// AutoDetectLineEnding tells if the line ending style is to be detected from
+	// the input: before the first line is scanned, up to ChunkSize bytes before
+	// the starting position are inspected, and if '\r' only line endings are
+	// dominant, Separator is set to '\r'; else it is set to '\n' (and a
+	// terminal '\r' is dropped unless KeepCR is set). It is ignored if Delimiter
+	// is set or Encoding is not UTF8.
+	AutoDetectLineEnding bool
+
+	// Unique tells if a line equal to the previously returned line is to be
+	// skipped, so only the first line of a run of identical lines is returned
+	// (the last one in the input). Lines are compared as they would be
+	// returned (e.g. after TrimSpace).
+	Unique bool
+
+	// Transform, if set, is called with the bytes of each line before it is
+	// returned (after TrimSpace is applied), and the returned slice is used as
+	// the line, e.g. to normalize lines or to strip escape sequences. It is
+	// called with a copy of the line, which it may modify in place, or it may
+	// return a different slice. Options filtering lines (e.g. SkipEmpty and
+	// Unique) see the transformed line.
+	Transform func(line []byte) []byte
+
+	// DelimiterLookback is the number of bytes of the already searched data
+	// that are searched again (or read again from the input) to detect a
+	// terminator straddling the boundary of reads. Detecting terminators needs
+	// one less than the length of the longest terminator: if Delimiter (or
+	// another terminator) is too long for it, scanning fails with an error
+	// (which NewOptionsStrict() returns). Default value is the length of the
+	// longest terminator minus 1.
+	DelimiterLookback int
+
+	// PosOffset is added to the positions reported by the Scanner: positions
+	// of returned lines, Pos(), and positions in errors and in OnProgress calls.
+	// It is useful if the input is a section of a larger input (e.g. an
+	// io.SectionReader), to get positions in the larger input.
+	// Positions given to the Scanner (e.g. the starting position and MinPos)
+	// are positions in the input, except where noted.
+	PosOffset int
+
+	// ValidateJSON tells if returned lines are to be validated as JSON (e.g.
+	// in NDJSON input, where the last line may be a partial write). The result
+	// is reported by LineJSON(). Lines are validated as they would be returned
+	// (e.g. after TrimSpace).
+	ValidateJSON bool
+
+	// SkipInvalidJSON tells if lines that are not valid JSON are to be skipped,
+	// including empty lines. It's only used if ValidateJSON is set.
+	SkipInvalidJSON bool
+
+	// MaxBytesRead is the max number of bytes that may be read from the input
+	// (as reported by Stats().BytesRead), since the Scanner was created or last
+	// Reset(). If reading the next chunk would exceed it, ErrReadBudgetExceeded
+	// is returned. Reads made ahead due to Prefetch may read one more chunk
+	// from the input. 0 means no limit.
+	MaxBytesRead int64
 }
 
 // New returns a new Scanner.
@@ -114,8 +509,63 @@ func New(r io.ReaderAt, pos int) *Scanner {
 }
 
 // NewOptions returns a new Scanner with the given Options.
-// Invalid option values are replaced with their default values.
+// Invalid option values are replaced with their default values, except a
+// DelimiterLookback too small for the terminators: that is reported as an
+// error by the first LineBytes() call (and all subsequent ones).
 func NewOptions(r io.ReaderAt, pos int, o *Options) *Scanner {
+	return New64(r, int64(pos), o)
+}
+
+// NewOptionsStrict is like NewOptions(), but instead of replacing invalid
+// option values with their default values, it returns an error. ChunkSize and
+// MaxBufferSize must be positive. A nil o means the default Options, just like
+// with NewOptions().
+func NewOptionsStrict(r io.ReaderAt, pos int, o *Options) (*Scanner, error) {
+	if pos < 0 {
+		return nil, fmt.Errorf("invalid position: %d (must not be negative)", pos)
+	}
+	if o == nil {
+		o = &Options{ChunkSize: DefaultChunkSize, MaxBufferSize: DefaultMaxBufferSize}
+	}
+	switch {
+	case o.ChunkSize <= 0:
+		return nil, fmt.Errorf("invalid ChunkSize: %d (must be positive)", o.ChunkSize)
+	case o.MaxBufferSize <= 0:
+		return nil, fmt.Errorf("invalid MaxBufferSize: %d (must be positive)", o.MaxBufferSize)
+	case o.ChunkSize > o.MaxBufferSize:
+		return nil, fmt.Errorf("invalid ChunkSize: %d (must not exceed MaxBufferSize: %d)", o.ChunkSize, o.MaxBufferSize)
+	case o.MaxLines < 0:
+		return nil, fmt.Errorf("invalid MaxLines: %d (must not be negative)", o.MaxLines)
+	case o.MaxLineSize < 0:
+		return nil, fmt.Errorf("invalid MaxLineSize: %d (must not be negative)", o.MaxLineSize)
+	case o.InitialBufferSize < 0:
+		return nil, fmt.Errorf("invalid InitialBufferSize: %d (must not be negative)", o.InitialBufferSize)
+	case o.MinPos < 0:
+		return nil, fmt.Errorf("invalid MinPos: %d (must not be negative)", o.MinPos)
+	case o.Encoding < UTF8 || o.Encoding > UTF16BE:
+		return nil, fmt.Errorf("invalid Encoding: %d", o.Encoding)
+	case o.SplitMode < Lines || o.SplitMode > Words:
+		return nil, fmt.Errorf("invalid SplitMode: %d", o.SplitMode)
+	case o.DelimiterLookback < 0:
+		return nil, fmt.Errorf("invalid DelimiterLookback: %d (must not be negative)", o.DelimiterLookback)
+	case o.GrowthChunks < 0:
+		return nil, fmt.Errorf("invalid GrowthChunks: %d (must not be negative)", o.GrowthChunks)
+	case o.MaxBytesRead < 0:
+		return nil, fmt.Errorf("invalid MaxBytesRead: %d (must not be negative)", o.MaxBytesRead)
+	case o.ReadTimeout < 0:
+		return nil, fmt.Errorf("invalid ReadTimeout: %v (must not be negative)", o.ReadTimeout)
+	}
+	s := NewOptions(r, pos, o)
+	if s.oerr != nil {
+		return nil, s.oerr
+	}
+	return s, nil
+}
+
+// New64 returns a new Scanner with the given Options, starting at an int64
+// position. Use this for inputs that may be larger than the max value of int.
+// Invalid option values are handled just like by NewOptions().
+func New64(r io.ReaderAt, pos int64, o *Options) *Scanner {
 	s := &Scanner{r: r, pos: pos}
 
 	if o != nil && o.ChunkSize > 0 {
@@ -128,44 +578,331 @@ func NewOptions(r io.ReaderAt, pos int, o *Options) *Scanner {
 	} else {
 		s.o.MaxBufferSize = DefaultMaxBufferSize
 	}
+	if o != nil && o.Separator != 0 {
+		s.o.Separator = o.Separator
+	} else {
+		s.o.Separator = DefaultSeparator
+	}
+	if o != nil && len(o.Delimiter) > 0 {
+		s.o.Delimiter = append([]byte(nil), o.Delimiter...)
+	}
+	if o != nil {
+		s.o.KeepTerminator = o.KeepTerminator
+		s.o.KeepCR = o.KeepCR
+		s.o.UnicodeLineBreaks = o.UnicodeLineBreaks
+		s.o.Decoder = o.Decoder
+		s.o.StripBOM = o.StripBOM
+		s.o.ValidatePos = o.ValidatePos
+		s.o.SplitMode = o.SplitMode
+		s.o.BufferPool = o.BufferPool
+		s.o.AdaptiveChunk = o.AdaptiveChunk
+		if o.GrowthChunks > 1 {
+			s.o.GrowthChunks = o.GrowthChunks
+			// Avoid overflow, chunks are capped by MaxBufferSize anyway:
+			if max := s.o.MaxBufferSize/s.o.ChunkSize + 1; s.o.GrowthChunks > max {
+				s.o.GrowthChunks = max
+			}
+		}
+		s.o.Prefetch = o.Prefetch
+		if o.MaxLines > 0 {
+			s.o.MaxLines = o.MaxLines
+		}
+		if o.MinPos > 0 {
+			s.o.MinPos = o.MinPos
+		}
+		s.o.ReadRetry = o.ReadRetry
+		if o.ReadTimeout > 0 {
+			s.o.ReadTimeout = o.ReadTimeout
+		}
+		s.o.OnProgress = o.OnProgress
+		s.o.OnRead = o.OnRead
+		s.o.SkipEmpty = o.SkipEmpty
+		s.o.TrimSpace = o.TrimSpace
+		s.o.ShrinkBuffer = o.ShrinkBuffer
+		s.o.InitialBufferSize = o.InitialBufferSize
+		if s.o.InitialBufferSize > s.o.MaxBufferSize {
+			s.o.InitialBufferSize = s.o.MaxBufferSize
+		}
+		s.o.MaxLineSize = o.MaxLineSize
+		s.o.CollapseBlankLines = o.CollapseBlankLines
+		s.o.Hash = o.Hash
+		s.o.AutoDetectLineEnding = o.AutoDetectLineEnding && len(o.Delimiter) == 0
+		s.o.Unique = o.Unique
+		s.o.Transform = o.Transform
+		s.o.PosOffset = o.PosOffset
+		s.o.ValidateJSON = o.ValidateJSON
+		s.o.SkipInvalidJSON = o.SkipInvalidJSON
+		if o.MaxBytesRead > 0 {
+			s.o.MaxBytesRead = o.MaxBytesRead
+		}
+		if o.Binary {
+			s.o.Binary = true
+			s.o.KeepCR, s.o.StripBOM, s.o.TrimSpace, s.o.UnicodeLineBreaks = true, false, false, false
+			s.o.Decoder, s.o.SplitMode = nil, Lines
+		}
+	}
+	if s.o.ChunkSize > s.o.MaxBufferSize {
+		// A chunk must fit into the buffer:
+		s.o.ChunkSize = s.o.MaxBufferSize
+	}
+	if s.o.GrowthChunks < 1 {
+		s.o.GrowthChunks = 1
+	}
+	if s.o.MaxLineSize <= 0 || s.o.MaxLineSize > s.o.MaxBufferSize {
+		s.o.MaxLineSize = s.o.MaxBufferSize
+	}
+	if s.o.SplitMode == Words {
+		s.split = ScanWordsReverse
+	}
+	if o != nil && !o.Binary && (o.Encoding == UTF16LE || o.Encoding == UTF16BE) {
+		s.o.Encoding = o.Encoding
+		s.sep = s.o.Encoding.encode(string(rune(s.o.Separator)))
+	}
+	if s.o.UnicodeLineBreaks {
+		for _, lb := range unicodeLineBreaks {
+			s.breaks = append(s.breaks, s.o.Encoding.encode(lb))
+		}
+	}
+	s.maxSepLen = 1
+	for _, sep := range append([][]byte{s.o.Delimiter, s.sep}, s.breaks...) {
+		if len(sep) > s.maxSepLen {
+			s.maxSepLen = len(sep)
+		}
+	}
+	if o != nil && o.DelimiterLookback > 0 {
+		s.o.DelimiterLookback = o.DelimiterLookback
+		if lookback := s.maxSepLen - 1; o.DelimiterLookback < lookback {
+			s.oerr = fmt.Errorf("invalid DelimiterLookback: %d (terminators of %d bytes need %d)",
+				o.DelimiterLookback, s.maxSepLen, lookback)
+			s.err = s.oerr
+		}
+	} else {
+		s.o.DelimiterLookback = s.maxSepLen - 1
+	}
+	if s.o.ValidatePos {
+		if size, ok := inputSize(r); ok && s.pos > size {
+			s.pos = size
+		}
+	}
+	s.mem = s.inMemory()
+	s.start = s.pos
 
 	return s
 }
 
+// Reset resets the Scanner to read from r, starting at the given position.
+// Options are preserved, and allocated internal buffers are reused
+// (or returned to BufferPool if set).
+func (s *Scanner) Reset(r io.ReaderAt, pos int) {
+	s.stopPrefetch()
+	s.releaseBuffers()
+	s.SetReader(r, pos)
+}
+
+// Reader returns the input the Scanner reads from.
+func (s *Scanner) Reader() io.ReaderAt {
+	return s.r
+}
+
+// SetReader sets the input of the Scanner to r, starting at the given position.
+// It's like Reset(), but allocated internal buffers are kept even if
+// BufferPool is set. Options are preserved, and any error (including io.EOF)
+// is cleared, except errors due to invalid Options.
+func (s *Scanner) SetReader(r io.ReaderAt, pos int) {
+	s.stopPrefetch()
+	s.r, s.pos = r, int64(pos)
+	s.mem = s.inMemory()
+	s.gen++
+	s.starts, s.part = nil, 0
+	s.start, s.trailingKnown = s.pos, false
+	s.err = s.oerr
+	s.buf = s.arr[len(s.arr):]
+	s.clean = 0
+	s.tail = 0
+	s.chunk = 0
+	s.last, s.ltail = 0, 0
+	s.canUnread = false
+	s.blank, s.lblank = false, false
+	s.hasPrev, s.lhasPrev = false, false
+	s.counted = false
+	s.detected = false
+	s.lpos, s.minKnown = 0, false
+	s.term, s.nterm, s.cr = s.term[:0], s.nterm[:0], s.cr[:0]
+	s.token = nil
+	s.lines = 0
+	s.stats = Stats{}
+	s.lens = lengthStats{}
+}
+
+// Close closes the input if it implements io.Closer, and returns the internal
+// buffers to BufferPool if set. It also stops the prefetch goroutine if
+// Prefetch is set, so Close() should be called if scanning is stopped early.
+// The Scanner must not be used after Close() unless it is Reset().
+func (s *Scanner) Close() error {
+	s.stopPrefetch()
+	s.releaseBuffers()
+	if c, ok := s.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
 // readMore reads more data from the input.
 func (s *Scanner) readMore() {
-	if s.pos == 0 {
+	if s.atMin() {
 		s.err = io.EOF
 		return
 	}
 	size := s.o.ChunkSize
-	if size > s.pos {
-		size = s.pos
+	if s.o.AdaptiveChunk || s.o.GrowthChunks > 1 {
+		if s.chunk > 0 {
+			size = s.chunk
+		}
+		// Do not exceed MaxBufferSize due to the grown chunk size:
+		if free := s.o.MaxBufferSize - len(s.buf); size > free && free >= s.o.ChunkSize {
+			size = free
+		}
+		// Grow the size for the next read (if no line is returned until then):
+		if s.o.AdaptiveChunk {
+			s.chunk = 2 * size
+		} else {
+			s.chunk = s.o.GrowthChunks * s.o.ChunkSize
+		}
+		if s.chunk > s.o.MaxBufferSize {
+			s.chunk = s.o.MaxBufferSize
+		}
+	}
+	if rem := s.pos - int64(s.o.MinPos); int64(size) > rem {
+		size = int(rem)
 	}
-	s.pos -= size
-
 	bufSize := size + len(s.buf)
 	if bufSize > s.o.MaxBufferSize {
-		s.err = ErrLongLine
+		s.err = s.longLine(bufSize, s.pos)
 		return
 	}
-	if cap(s.buf2) >= bufSize {
-		s.buf2 = s.buf2[:size]
-	} else {
-		s.buf2 = make([]byte, size, bufSize)
+	if s.o.MaxBytesRead > 0 && s.stats.BytesRead+int64(size) > s.o.MaxBytesRead {
+		s.err = ErrReadBudgetExceeded
+		return
+	}
+	s.pos -= int64(size)
+
+	if s.mem != nil {
+		s.readMem(size)
+		return
+	}
+
+	if s.o.ShrinkBuffer {
+		s.shrink(bufSize)
 	}
 
-	// ReadAt attempts to read full buff!
+	// Chunks are read in front of buf, so prepending is amortized O(1):
+	start := len(s.arr) - cap(s.buf) // start of buf in arr
+	if start < size {
+		// Not enough room in front of buf: move it to the end of arr,
+		// and grow arr if buf would occupy more than half of it.
+		arr := s.arr
+		if bufSize > len(arr)/2 && len(arr) < s.o.MaxBufferSize {
+			newSize := 2 * len(arr)
+			if newSize < bufSize {
+				newSize = bufSize
+			}
+			if newSize < s.o.InitialBufferSize {
+				newSize = s.o.InitialBufferSize
+			}
+			if newSize > s.o.MaxBufferSize {
+				newSize = s.o.MaxBufferSize
+			}
+			arr = s.alloc(newSize)
+		}
+		start = len(arr) - len(s.buf)
+		copy(arr[start:], s.buf)
+		if len(arr) != len(s.arr) {
+			s.free(s.arr)
+		}
+		s.arr, s.buf = arr, arr[start:]
+	}
+	chunk := s.arr[start-size : start]
+
 	var n int
-	n, s.err = s.r.ReadAt(s.buf2, int64(s.pos))
-	// io.ReadAt() allows returning either nil or io.EOF if buf is read fully and EOF reached:
-	if s.err == io.EOF && n == size {
-		// Do not treat that EOF as an error, process read data:
-		s.err = nil
+	if s.o.OnRead != nil {
+		t := time.Now()
+		n, s.err = s.readAt(chunk, s.pos)
+		s.o.OnRead(s.pos, n, time.Since(t), s.err)
+	} else {
+		n, s.err = s.readAt(chunk, s.pos)
+	}
+	// io.ReadAt() allows returning either nil or io.EOF if buf is read fully and EOF reached
+	// (some readers report io.ErrUnexpectedEOF instead):
+	if s.err == io.EOF || s.err == io.ErrUnexpectedEOF {
+		if n == size {
+			// Do not treat that EOF as an error, process read data:
+			s.err = nil
+		} else {
+			// We're not reading from the start, so there must have been more data
+			// if the position would be valid:
+			s.err = ErrPosBeyondEnd
+		}
 	}
 	if s.err == nil {
-		s.buf, s.buf2 = append(s.buf2, s.buf...), s.buf
+		s.buf = s.arr[start-size : start+len(s.buf)]
+		s.prefetch()
+		if s.o.OnProgress != nil {
+			s.o.OnProgress(int(s.pos) + s.o.PosOffset)
+		}
+	}
+}
+
+// readMem is the readMore() of in-memory inputs: buf is extended in mem to
+// the chunk of the given size before it, without copying.
+func (s *Scanner) readMem(size int) {
+	end := s.pos + int64(size+len(s.buf))
+	if end > int64(len(s.mem)) {
+		s.err = ErrPosBeyondEnd
+		return
+	}
+	s.buf = s.mem[s.pos:end]
+	s.count(size, 0)
+	if s.o.OnRead != nil {
+		s.o.OnRead(s.pos, size, 0, nil)
+	}
+	if s.o.OnProgress != nil {
+		s.o.OnProgress(int(s.pos) + s.o.PosOffset)
+	}
+}
+
+// longLine returns the error reporting a long line.
+func (s *Scanner) longLine(size int, pos int64) error {
+	return &LongLineError{
+		MaxBufferSize: s.o.MaxBufferSize,
+		MaxLineSize:   s.o.MaxLineSize,
+		AttemptedSize: size,
+		Pos:           pos + int64(s.o.PosOffset),
+	}
+}
+
+// shrink replaces the backing array of buf with a smaller one if it is much
+// larger than needed to hold bufSize bytes.
+func (s *Scanner) shrink(bufSize int) {
+	size := 2 * bufSize
+	if size < 2*s.o.ChunkSize {
+		size = 2 * s.o.ChunkSize
+	}
+	if size < s.o.InitialBufferSize {
+		size = s.o.InitialBufferSize
 	}
+	if len(s.arr) <= 4*size {
+		return
+	}
+	arr := s.alloc(size)
+	if len(arr) > 4*size {
+		// The pooled buffer is not smaller:
+		s.free(arr)
+		arr = make([]byte, size)
+	}
+	start := len(arr) - len(s.buf)
+	copy(arr[start:], s.buf)
+	s.free(s.arr)
+	s.arr, s.buf = arr, arr[start:]
 }
 
 // LineBytes returns the bytes of the next line from the input and its absolute
@@ -178,24 +915,207 @@ func (s *Scanner) readMore() {
 // The returned line slice shares data with the internal buffer of the Scanner,
 // and its content may be overwritten in subsequent calls to LineBytes() or Line().
 // If you need to retain the line data, make a copy of it or use the Line() method.
+// If Transform is set, the returned slice is the one returned by Transform,
+// which may be a fresh allocation.
 func (s *Scanner) LineBytes() (line []byte, pos int, err error) {
+	return s.LineBytesContext(context.Background())
+}
+
+// LineBytesRange is like LineBytes(), but it also returns the end position of
+// the line: the position right after the last byte of the line in the input,
+// before the stripped terminator (and terminal '\r' if dropped). If
+// KeepTerminator is set, the terminator is part of the line.
+// line is the input between start and end unless it is transformed
+// (e.g. decoded using Encoding or Decoder).
+func (s *Scanner) LineBytesRange() (line []byte, start, end int, err error) {
+	line, start, err = s.LineBytes()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return line, start, int(s.end) + s.o.PosOffset, nil
+}
+
+// LineBytesTerm is like LineBytes(), but it also returns the terminator that
+// followed the line in the input (including a dropped terminal '\r'), which is
+// empty for the line at the starting position. If KeepTerminator is set, the
+// terminator is also part of the line. This makes it possible to reconstruct
+// the input exactly, even if it has mixed line endings.
+//
+// Terminators are only known in Lines SplitMode without a custom split
+// function, else term is always empty. term shares data with an internal
+// buffer of the Scanner just like line.
+func (s *Scanner) LineBytesTerm() (line []byte, pos int, term []byte, err error) {
+	if line, pos, err = s.LineBytes(); err != nil {
+		return nil, 0, nil, err
+	}
+	s.tterm = append(append(s.tterm[:0], s.cr...), s.term...)
+	return line, pos, s.tterm, nil
+}
+
+// LineBytes64 is like LineBytes(), but returns the position as an int64.
+// Use this for inputs that may be larger than the max value of int.
+func (s *Scanner) LineBytes64() (line []byte, pos int64, err error) {
+	line, pos, err = s.lineBytes(context.Background())
+	return line, s.outPos(pos, err), err
+}
+
+// LineBytesContext is like LineBytes(), but ctx is checked before each read
+// from the input, and if ctx is done, ctx.Err() is returned.
+// An error returned due to ctx does not invalidate the Scanner, scanning
+// may be continued with a subsequent call.
+func (s *Scanner) LineBytesContext(ctx context.Context) (line []byte, pos int, err error) {
+	var pos64 int64
+	line, pos64, err = s.lineBytes(ctx)
+	return line, int(s.outPos(pos64, err)), err
+}
+
+// outPos returns the position of a line returned by lineBytes() as reported
+// to the caller: PosOffset is added unless no position is reported due to
+// an error.
+func (s *Scanner) outPos(pos int64, err error) int64 {
+	if err != nil && pos == 0 {
+		return 0
+	}
+	return pos + int64(s.o.PosOffset)
+}
+
+// lineBytes is the implementation of LineBytesContext(), returning the
+// position as an int64.
+func (s *Scanner) lineBytes(ctx context.Context) (line []byte, pos int64, err error) {
+	s.canUnread = false
+	if s.err == io.EOF {
+		s.nextPart()
+	}
+	if s.err != nil {
+		return nil, 0, s.err
+	}
+	if s.o.MaxLines > 0 && s.lines >= s.o.MaxLines {
+		s.err = ErrMaxLines
+		return nil, 0, s.err
+	}
+	blank := s.blank
+	for {
+		line, pos, err = s.nextLine(ctx)
+		if err != nil {
+			return
+		}
+		if s.o.TrimSpace {
+			line = bytes.TrimSpace(line)
+		}
+		if s.o.Transform != nil {
+			// Transform works on a copy, the line may be returned again (e.g. if unread):
+			s.xbuf = append(s.xbuf[:0], line...)
+			line = s.o.Transform(s.xbuf)
+		}
+		if !s.skip(line) {
+			s.lblank, s.blank = blank, len(line) == 0
+			if s.o.Unique {
+				s.lprev, s.prev = s.prev, append(s.lprev[:0], line...)
+				s.lhasPrev, s.hasPrev = s.hasPrev, true
+			}
+			s.lpos = pos
+			if !s.counted {
+				if s.o.Hash != nil {
+					s.o.Hash.Write(line)
+				}
+				s.lens.add(len(line))
+			}
+			s.counted = false
+			return
+		}
+		// Skipped lines are not counted:
+		s.lines--
+	}
+}
+
+// skip tells if line is not to be returned due to SkipEmpty,
+// CollapseBlankLines, SkipInvalidJSON or Unique.
+func (s *Scanner) skip(line []byte) bool {
+	if len(line) == 0 && (s.o.SkipEmpty || s.o.CollapseBlankLines && s.blank) {
+		return true
+	}
+	if s.o.ValidateJSON {
+		if s.valid = json.Valid(line); !s.valid && s.o.SkipInvalidJSON {
+			return true
+		}
+	}
+	return s.o.Unique && s.hasPrev && bytes.Equal(line, s.prev)
+}
+
+// nextLine returns the next line (or token) from the input, and its absolute
+// byte-position.
+func (s *Scanner) nextLine(ctx context.Context) (line []byte, pos int64, err error) {
+	if s.o.AutoDetectLineEnding && !s.detected {
+		s.detectLineEnding()
+	}
+	if s.err == io.EOF {
+		s.nextPart()
+	}
 	if s.err != nil {
 		return nil, 0, s.err
 	}
+	if s.split != nil || s.o.SplitMode == Paragraphs {
+		// Terminators are only tracked in Lines mode:
+		s.term, s.nterm = s.term[:0], s.nterm[:0]
+		if s.split != nil {
+			return s.splitToken(ctx)
+		}
+		return s.paragraph(ctx)
+	}
 
 	for {
-		lineStart := bytes.LastIndexByte(s.buf, '\n')
-		if lineStart >= 0 {
+		// Only search in data that may contain a separator (not yet searched
+		// data, plus an overlap for multi-byte separators straddling the boundary):
+		data := s.buf[:len(s.buf)-s.tail]
+		if unsearched := len(data) - s.clean + s.o.DelimiterLookback; unsearched < len(data) {
+			data = data[:unsearched]
+		}
+		sepStart, sepLen := s.lastSep(data)
+		if sepStart >= 0 {
+			s.clean = 0
 			// We have a complete line:
-			line, s.buf = dropCR(s.buf[lineStart+1:]), s.buf[:lineStart]
-			return line, s.pos + lineStart + 1, nil
+			lineStart := sepStart + sepLen
+			pos = s.pos + int64(lineStart)
+			if size := len(s.buf) - s.tail - lineStart; size > s.o.MaxLineSize {
+				s.err = s.longLine(size, pos)
+				return nil, 0, s.err
+			}
+			s.last, s.ltail = len(s.buf), s.tail
+			s.term, s.nterm = s.nterm, append(s.term[:0], s.buf[sepStart:lineStart]...)
+			if s.o.KeepTerminator {
+				line, s.buf, s.tail = s.buf[lineStart:], s.buf[:lineStart], sepLen
+			} else {
+				line, s.buf = s.buf[lineStart:], s.buf[:sepStart]
+			}
+			s.last -= len(s.buf)
+			s.emit()
+			line, err = s.finish(line, pos)
+			return line, pos, err
+		}
+		// Need more data (a delimiter may straddle the chunk boundary,
+		// but we keep all unreturned data in buf, so reading more will find it):
+		s.clean = len(s.buf) - s.tail
+		if s.clean > s.o.MaxLineSize {
+			s.err = s.longLine(s.clean, s.pos)
+			return nil, 0, s.err
+		}
+		if err = ctx.Err(); err != nil {
+			return nil, 0, err
 		}
-		// Need more data:
 		s.readMore()
 		if s.err != nil {
 			if s.err == io.EOF {
 				if len(s.buf) > 0 {
-					return dropCR(s.buf), 0, nil
+					// Subsequent calls report io.EOF due to s.err
+					s.last, s.ltail = len(s.buf), s.tail
+					s.term, s.nterm = s.nterm, s.term[:0]
+					line, s.buf, s.tail = s.buf, s.buf[:0], 0
+					s.emit()
+					line, err = s.finish(line, s.pos)
+					return line, s.pos, err
+				}
+				if s.nextPart() {
+					continue
 				}
 			}
 			return nil, 0, s.err
@@ -203,21 +1123,394 @@ func (s *Scanner) LineBytes() (line []byte, pos int, err error) {
 	}
 }
 
+// atMin tells if the start of the input (or MinPos) is reached.
+func (s *Scanner) atMin() bool {
+	return s.pos <= int64(s.o.MinPos)
+}
+
+// emit registers that a line is returned.
+func (s *Scanner) emit() {
+	s.lines++
+	s.chunk = 0
+	s.canUnread = true
+}
+
+// Peek returns the next line from the input and its absolute byte-position
+// just like LineBytes(), but without advancing the Scanner: a subsequent call
+// to LineBytes() (or Line()) returns the same line.
+//
+// The returned line slice shares data with the internal buffer of the Scanner
+// just like the one returned by LineBytes().
+func (s *Scanner) Peek() (line []byte, pos int, err error) {
+	lines := s.lines
+	line, pos, err = s.LineBytes()
+	if s.lines != lines {
+		s.unread()
+	}
+	return
+}
+
+// UnreadLine unreads the last returned line, so the next call to LineBytes()
+// (or Line()) returns it again. Only one line can be unread: it returns
+// ErrInvalidUnread if the last call did not return a line (or it was already
+// unread).
+func (s *Scanner) UnreadLine() error {
+	if !s.canUnread {
+		return ErrInvalidUnread
+	}
+	s.unread()
+	return nil
+}
+
+// unread restores the state before the last returned line was read,
+// so the next call returns the same line.
+// It must only be called right after a line was returned.
+func (s *Scanner) unread() {
+	s.canUnread = false
+	s.blank = s.lblank
+	s.prev, s.lprev = s.lprev, s.prev
+	s.hasPrev = s.lhasPrev
+	s.term, s.nterm = s.nterm, s.term
+	s.counted = true
+	s.buf = s.buf[:len(s.buf)+s.last]
+	s.tail = s.ltail
+	s.clean = 0
+	if s.err == io.EOF {
+		// It was the first line of the input:
+		s.err = nil
+	}
+	s.lines--
+}
+
 // Line returns the next line from the input and its absolute byte-position.
 // Line ending is cut from the line. Empty lines are also returned.
 // After returning the last line (which is the first in the input),
 // subsequent calls report io.EOF.
 func (s *Scanner) Line() (line string, pos int, err error) {
+	return s.LineContext(context.Background())
+}
+
+// Line64 is like Line(), but returns the position as an int64.
+// Use this for inputs that may be larger than the max value of int.
+func (s *Scanner) Line64() (line string, pos int64, err error) {
+	var lineBytes []byte
+	lineBytes, pos, err = s.LineBytes64()
+	line = string(lineBytes)
+	return
+}
+
+// LineContext is like Line(), but ctx is checked before each read
+// from the input, and if ctx is done, ctx.Err() is returned.
+// An error returned due to ctx does not invalidate the Scanner, scanning
+// may be continued with a subsequent call.
+func (s *Scanner) LineContext(ctx context.Context) (line string, pos int, err error) {
 	var lineBytes []byte
-	lineBytes, pos, err = s.LineBytes()
+	lineBytes, pos, err = s.LineBytesContext(ctx)
 	line = string(lineBytes)
 	return
 }
 
-// dropCR drops a terminal \r from the data.
-func dropCR(data []byte) []byte {
-	if len(data) > 0 && data[len(data)-1] == '\r' {
+// lastSep returns the index and length of the last line separator in data.
+// The returned index is -1 if data does not contain a separator.
+// data must be a prefix of buf.
+func (s *Scanner) lastSep(data []byte) (idx, size int) {
+	return s.lastSepAt(data, s.pos)
+}
+
+// lastSepAt is like lastSep(), but data is at the given absolute position.
+func (s *Scanner) lastSepAt(data []byte, pos int64) (idx, size int) {
+	switch {
+	case len(s.o.Delimiter) > 0:
+		idx, size = s.lastIndex(data, pos, s.o.Delimiter), len(s.o.Delimiter)
+	case s.sep != nil:
+		idx, size = s.lastIndex(data, pos, s.sep), len(s.sep)
+	default:
+		idx, size = bytes.LastIndexByte(data, s.o.Separator), 1
+	}
+	for _, lb := range s.breaks {
+		if i := s.lastIndex(data[idx+1:], pos+int64(idx+1), lb); i >= 0 {
+			idx, size = idx+1+i, len(lb)
+		}
+	}
+	return
+}
+
+// lastIndex returns the index of the last occurrence of sep in data, whose
+// absolute position is pos. If Encoding is not UTF8, only occurrences
+// at code unit boundaries are considered.
+func (s *Scanner) lastIndex(data []byte, pos int64, sep []byte) int {
+	i := bytes.LastIndex(data, sep)
+	if s.o.Encoding == UTF8 {
+		return i
+	}
+	for i >= 0 && (pos+int64(i))%2 != 0 {
+		i = bytes.LastIndex(data[:i+len(sep)-1], sep)
+	}
+	return i
+}
+
+// index is like lastIndex(), but returns the index of the first occurrence.
+func (s *Scanner) index(data []byte, pos int64, sep []byte) int {
+	for i := 0; ; {
+		j := bytes.Index(data[i:], sep)
+		if j < 0 {
+			return -1
+		}
+		if s.o.Encoding == UTF8 || (pos+int64(i+j))%2 == 0 {
+			return i + j
+		}
+		i += j + 1
+	}
+}
+
+// finish applies the final transformations to a line to be returned which
+// starts at the given absolute position.
+func (s *Scanner) finish(line []byte, pos int64) ([]byte, error) {
+	s.end = pos + int64(len(line))
+	s.cr = s.cr[:0]
+	if s.split == nil && s.dropsCR() {
+		n := s.o.Encoding.crLen(line)
+		s.end -= int64(n)
+		s.cr = append(s.cr, line[len(line)-n:]...)
+	}
+	if pos == 0 && (s.o.StripBOM || s.o.Encoding != UTF8) {
+		line = bytes.TrimPrefix(line, s.o.Encoding.bom())
+	}
+	if s.o.Encoding != UTF8 {
+		s.dec = s.o.Encoding.decodeUTF16(s.dec[:0], line)
+		line = s.dec
+	}
+	if s.o.Decoder != nil {
+		var err error
+		if s.tbuf, err = transform(s.o.Decoder, s.tbuf[:0], line); err != nil {
+			return nil, err
+		}
+		line = s.tbuf
+	}
+	if s.split != nil {
+		// Split functions handle terminators themselves.
+		return line, nil
+	}
+	return s.dropCR(line), nil
+}
+
+// Scan advances the Scanner to the next line (previous in the source), which
+// will then be available through the Bytes() or Text() methods.
+// It returns false when the scan stops, either by reaching the end of the input
+// or an error. After Scan() returns false, Err() returns any error that occurred
+// during scanning, except that if it was io.EOF, Err() will return nil.
+//
+// Scan, Bytes, Text and Err are provided for compatibility with bufio.Scanner.
+func (s *Scanner) Scan() bool {
+	var err error
+	s.token, _, err = s.LineBytes()
+	return err == nil
+}
+
+// Bytes returns the most recent line generated by a call to Scan().
+// The underlying array may point to data that will be overwritten by a
+// subsequent call to Scan(), just like with LineBytes().
+func (s *Scanner) Bytes() []byte {
+	return s.token
+}
+
+// Text returns the most recent line generated by a call to Scan()
+// as a newly allocated string.
+func (s *Scanner) Text() string {
+	return string(s.token)
+}
+
+// LineNumber returns the number of lines returned so far, which is also the
+// number of the last returned line counted from the end (backward from the
+// starting position): 1 for the first returned line.
+func (s *Scanner) LineNumber() int {
+	return s.lines
+}
+
+// HadTrailingNewline tells if the input ends with a line terminator at the
+// starting position: Delimiter if set, else Separator (a '\n' by default).
+// The bytes before the starting position are read when it is first called;
+// if reading them fails, false is returned.
+func (s *Scanner) HadTrailingNewline() bool {
+	if s.o.AutoDetectLineEnding && !s.detected {
+		s.detectLineEnding()
+	}
+	if !s.trailingKnown {
+		s.trailing = s.termBefore(s.start, int64(s.o.MinPos))
+		s.trailingKnown = true
+	}
+	return s.trailing
+}
+
+// Truncated tells if the last returned line was cut at MinPos: it starts at
+// MinPos (which is not 0), and the input has no line terminator right before
+// MinPos (Delimiter if set, else Separator). The bytes before MinPos are read
+// when it is first needed; if reading them fails, the line is reported as
+// truncated.
+func (s *Scanner) Truncated() bool {
+	if s.o.MinPos == 0 || s.lines == 0 || s.lpos != int64(s.o.MinPos) {
+		return false
+	}
+	if !s.minKnown {
+		s.minTerm = s.termBefore(int64(s.o.MinPos), 0)
+		s.minKnown = true
+	}
+	return !s.minTerm
+}
+
+// termBefore tells if there is a line terminator right before pos, not
+// before min. It reads the terminator bytes from the input.
+func (s *Scanner) termBefore(pos, min int64) bool {
+	term := s.terminator()
+	if pos-int64(len(term)) < min {
+		return false
+	}
+	end := make([]byte, len(term))
+	n, err := s.readAside(end, pos-int64(len(end)))
+	return (err == nil || err == io.EOF) && n == len(end) && bytes.Equal(end, term)
+}
+
+// terminator returns the line terminator: Delimiter if set, else the
+// encoded Separator.
+func (s *Scanner) terminator() []byte {
+	switch {
+	case len(s.o.Delimiter) > 0:
+		return s.o.Delimiter
+	case s.sep != nil:
+		return s.sep
+	}
+	return []byte{s.o.Separator}
+}
+
+// Pos returns the position of the data read last from the input, which is
+// the start of the not yet read region of the input: bytes before Pos() are
+// yet to be read.
+func (s *Scanner) Pos() int {
+	return int(s.pos) + s.o.PosOffset
+}
+
+// AtEOF tells if the end of scanning is reached: the start of the input (or
+// MinPos) is reached and all lines are returned, so subsequent calls report
+// io.EOF. It may report false even if no more lines remain, which is then
+// reported by the next call.
+func (s *Scanner) AtEOF() bool {
+	return s.err == io.EOF && s.part == 0
+}
+
+// Buffered returns the number of bytes that are read from the input, but
+// not yet returned in lines. Pos() + Buffered() is the position up to which
+// the input is yet to be scanned.
+func (s *Scanner) Buffered() int {
+	return len(s.buf)
+}
+
+// Stats contains statistics about the work done by a Scanner.
+type Stats struct {
+	// BytesRead is the number of bytes read from the input.
+	// Read data is kept until it's returned in lines, so scanning reads
+	// each byte of the input once: after a full scan, BytesRead is the size
+	// of the scanned input (unless the input is also read to inspect data
+	// outside of the returned lines, e.g. by HadTrailingNewline()).
+	BytesRead int64
+
+	// ReadCalls is the number of ReadAt calls made to the input.
+	ReadCalls int
+
+	// LinesReturned is the number of lines returned.
+	LinesReturned int
+}
+
+// Stats returns the statistics of the Scanner, accumulated since it was
+// created or last Reset().
+//
+// Reads made ahead due to Prefetch are counted when their data is used
+// (or discarded).
+func (s *Scanner) Stats() Stats {
+	st := s.stats
+	st.LinesReturned = s.lines
+	return st
+}
+
+// lengthStats holds statistics of line lengths.
+type lengthStats struct {
+	min, max, total, count int
+}
+
+// add registers a line of length n.
+func (ls *lengthStats) add(n int) {
+	if ls.count == 0 || n < ls.min {
+		ls.min = n
+	}
+	if n > ls.max {
+		ls.max = n
+	}
+	ls.total += n
+	ls.count++
+}
+
+// LengthStats returns statistics of the lengths of the returned lines,
+// accumulated since the Scanner was created or last Reset(): the length of the
+// shortest and longest line, the sum of the lengths and the number of lines
+// (so the average length is total/count). Lengths are in bytes, as the lines
+// are returned by LineBytes() (e.g. including the terminator if KeepTerminator
+// is set). A line that is unread (or peeked) is only counted once.
+// All values are 0 if no lines were returned.
+func (s *Scanner) LengthStats() (min, max, total, count int) {
+	return s.lens.min, s.lens.max, s.lens.total, s.lens.count
+}
+
+// count registers reading n bytes from the input using the given number of
+// ReadAt calls.
+func (s *Scanner) count(n, calls int) {
+	s.stats.BytesRead += int64(n)
+	s.stats.ReadCalls += calls
+}
+
+// Err returns the first non-EOF error that was encountered by the Scanner.
+func (s *Scanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+// dropCR drops a terminal \r from the data if the separator is \n,
+// and neither terminators nor CRs are kept.
+func (s *Scanner) dropCR(data []byte) []byte {
+	if s.dropsCR() && len(data) > 0 && data[len(data)-1] == '\r' {
 		return data[0 : len(data)-1]
 	}
 	return data
 }
+
+// detectLineEnding sets Separator based on the line endings in the last
+// chunk before the starting position.
+func (s *Scanner) detectLineEnding() {
+	s.detected = true
+	if s.o.Encoding != UTF8 {
+		return
+	}
+	size := int64(s.o.ChunkSize)
+	if rem := s.start - int64(s.o.MinPos); size > rem {
+		size = rem
+	}
+	data := make([]byte, size)
+	n, err := s.readAside(data, s.start-size)
+	if err != nil && err != io.EOF {
+		return // The error will be reported when reading lines
+	}
+	data = data[:n]
+
+	lfs := bytes.Count(data, []byte{'\n'})
+	crs := bytes.Count(data, []byte{'\r'}) - bytes.Count(data, []byte("\r\n"))
+	if crs > lfs {
+		s.o.Separator = '\r'
+	} else {
+		s.o.Separator = '\n'
+	}
+}
+
+// dropsCR tells if terminal \r are dropped from lines.
+func (s *Scanner) dropsCR() bool {
+	return !s.o.KeepTerminator && !s.o.KeepCR && len(s.o.Delimiter) == 0 && s.o.Separator == '\n'
+}
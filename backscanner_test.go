@@ -1,9 +1,15 @@
 package backscanner
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/icza/mighty"
 )
@@ -14,6 +20,7 @@ func TestDefaults(t *testing.T) {
 	scanner := New(nil, 0)
 	eq(DefaultChunkSize, scanner.o.ChunkSize)
 	eq(DefaultMaxBufferSize, scanner.o.MaxBufferSize)
+	eq(byte(DefaultSeparator), scanner.o.Separator)
 
 	scanner = NewOptions(nil, 0, &Options{
 		ChunkSize:     -1,
@@ -21,6 +28,110 @@ func TestDefaults(t *testing.T) {
 	})
 	eq(DefaultChunkSize, scanner.o.ChunkSize)
 	eq(DefaultMaxBufferSize, scanner.o.MaxBufferSize)
+
+	// ChunkSize must not exceed MaxBufferSize:
+	scanner = NewOptions(nil, 0, &Options{ChunkSize: 2 << 20})
+	eq(DefaultMaxBufferSize, scanner.o.ChunkSize)
+	scanner = NewString("a\nb", &Options{ChunkSize: 10, MaxBufferSize: 4})
+	eq(4, scanner.o.ChunkSize)
+	line, _, err := scanner.Line()
+	eq("b", line)
+	eq(nil, err)
+
+	scanner = NewOptions(nil, 0, &Options{Delimiter: []byte("<br>")})
+	eq(3, scanner.o.DelimiterLookback)
+	scanner = NewOptions(nil, 0, &Options{Delimiter: []byte("<br>"), DelimiterLookback: -1})
+	eq(3, scanner.o.DelimiterLookback)
+}
+
+func TestDelimiterLookback(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	// A too small lookback is reported when scanning, also after Reset():
+	input := "a<br>b"
+	scanner := NewString(input, &Options{Delimiter: []byte("<br>"), DelimiterLookback: 1})
+	for i := 0; i < 2; i++ {
+		_, _, err := scanner.Line()
+		eq("invalid DelimiterLookback: 1 (terminators of 4 bytes need 3)", err.Error())
+		scanner.Reset(strings.NewReader(input), len(input))
+	}
+
+	// A larger lookback searches more data again:
+	for _, lookback := range []int{3, 4, 10} {
+		for chunkSize := 1; chunkSize <= 8; chunkSize++ {
+			scanner := NewString("a<br>bc<br><br>d", &Options{Delimiter: []byte("<br>"), DelimiterLookback: lookback, ChunkSize: chunkSize})
+			lines, _, err := scanner.Lines(10)
+			deq([]string{"d", "", "bc", "a"}, lines)
+			eq(nil, err)
+		}
+	}
+}
+
+func TestNewOptionsStrict(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	valid := Options{ChunkSize: 10, MaxBufferSize: 100}
+	cases := []struct {
+		pos    int
+		modify func(o *Options)
+		err    string
+	}{
+		{0, func(o *Options) {}, ""},
+		{-1, func(o *Options) {}, "invalid position: -1 (must not be negative)"},
+		{0, func(o *Options) { o.ChunkSize = 0 }, "invalid ChunkSize: 0 (must be positive)"},
+		{0, func(o *Options) { o.MaxBufferSize = -5 }, "invalid MaxBufferSize: -5 (must be positive)"},
+		{0, func(o *Options) { o.ChunkSize = 101 }, "invalid ChunkSize: 101 (must not exceed MaxBufferSize: 100)"},
+		{0, func(o *Options) { o.MaxLines = -1 }, "invalid MaxLines: -1 (must not be negative)"},
+		{0, func(o *Options) { o.InitialBufferSize = -1 }, "invalid InitialBufferSize: -1 (must not be negative)"},
+		{0, func(o *Options) { o.MinPos = -1 }, "invalid MinPos: -1 (must not be negative)"},
+		{0, func(o *Options) { o.Encoding = 3 }, "invalid Encoding: 3"},
+		{0, func(o *Options) { o.SplitMode = -1 }, "invalid SplitMode: -1"},
+		{0, func(o *Options) { o.DelimiterLookback = -1 }, "invalid DelimiterLookback: -1 (must not be negative)"},
+		{0, func(o *Options) { o.GrowthChunks = -1 }, "invalid GrowthChunks: -1 (must not be negative)"},
+		{0, func(o *Options) { o.MaxBytesRead = -1 }, "invalid MaxBytesRead: -1 (must not be negative)"},
+		{0, func(o *Options) { o.ReadTimeout = -time.Second }, "invalid ReadTimeout: -1s (must not be negative)"},
+		{0, func(o *Options) { o.Delimiter, o.DelimiterLookback = []byte("<br>"), 2 },
+			"invalid DelimiterLookback: 2 (terminators of 4 bytes need 3)"},
+		{0, func(o *Options) { o.UnicodeLineBreaks, o.DelimiterLookback = true, 1 },
+			"invalid DelimiterLookback: 1 (terminators of 3 bytes need 2)"},
+		{0, func(o *Options) { o.Delimiter, o.DelimiterLookback = []byte("<br>"), 3 }, ""},
+	}
+
+	for _, c := range cases {
+		o := valid
+		c.modify(&o)
+		scanner, err := NewOptionsStrict(nil, c.pos, &o)
+		if c.err == "" {
+			eq(nil, err)
+			eq(10, scanner.o.ChunkSize)
+			eq(100, scanner.o.MaxBufferSize)
+		} else {
+			eq(c.err, err.Error())
+			eq(true, scanner == nil)
+		}
+	}
+
+	// nil means the defaults:
+	scanner, err := NewOptionsStrict(nil, 0, nil)
+	eq(nil, err)
+	eq(DefaultChunkSize, scanner.o.ChunkSize)
+	eq(DefaultMaxBufferSize, scanner.o.MaxBufferSize)
+	_, err = NewOptionsStrict(nil, -1, nil)
+	eq("invalid position: -1 (must not be negative)", err.Error())
+}
+
+func TestNilReader(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	scanner := New(nil, 10)
+	_, _, err := scanner.Line()
+	eq(ErrNilReader, err)
+	eq(false, scanner.HadTrailingNewline())
+
+	// Nothing to read:
+	scanner = New(nil, 0)
+	_, _, err = scanner.Line()
+	eq(io.EOF, err)
 }
 
 func TestScanner(t *testing.T) {
@@ -83,39 +194,1754 @@ func TestScanner(t *testing.T) {
 	}
 }
 
+func TestSeparator(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line string
+		pos  int
+		err  error
+	}
+
+	cases := []struct {
+		input string
+		sep   byte
+		exps  []result
+	}{
+		// \r only line endings (classic Mac)
+		{
+			input: "Line1\rLine2\rLine3",
+			sep:   '\r',
+			exps: []result{
+				{"Line3", 12, nil},
+				{"Line2", 6, nil},
+				{"Line1", 0, nil},
+				{"", 0, io.EOF},
+			},
+		},
+		// Record separator with mixed content: \n and \r are kept
+		{
+			input: "a\r\nb\x1ec\r\x1e\x1ed\ne\x1e",
+			sep:   0x1e,
+			exps: []result{
+				{"", 13, nil},
+				{"d\ne", 9, nil},
+				{"", 8, nil},
+				{"c\r", 5, nil},
+				{"a\r\nb", 0, nil},
+				{"", 0, io.EOF},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		// Test with different chunk sizes so separators land on chunk boundaries:
+		for _, chunkSize := range []int{1, 2, 3, 4, 5, 6, 100} {
+			scanner := NewOptions(strings.NewReader(c.input), len(c.input), &Options{ChunkSize: chunkSize, Separator: c.sep})
+			i := 0
+			for {
+				line, pos, err := scanner.Line()
+				exp := c.exps[i]
+				eq(exp.line, line)
+				eq(exp.pos, pos)
+				eq(exp.err, err)
+				if err == io.EOF {
+					eq(len(c.exps)-1, i)
+					break
+				}
+				i++
+			}
+		}
+	}
+}
+
+func TestDelimiter(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line string
+		pos  int
+		err  error
+	}
+
+	cases := []struct {
+		input string
+		delim string
+		exps  []result
+	}{
+		// \r\n treated atomically, lone \r and \n are kept
+		{
+			input: "Line1\r\nLi\rne2\r\nLi\nne3",
+			delim: "\r\n",
+			exps: []result{
+				{"Li\nne3", 15, nil},
+				{"Li\rne2", 7, nil},
+				{"Line1", 0, nil},
+				{"", 0, io.EOF},
+			},
+		},
+		// Arbitrary byte sequence, including adjacent and trailing delimiters
+		{
+			input: "rec1<END>rec2<END><END>rec<3<END>",
+			delim: "<END>",
+			exps: []result{
+				{"", 33, nil},
+				{"rec<3", 23, nil},
+				{"", 18, nil},
+				{"rec2", 9, nil},
+				{"rec1", 0, nil},
+				{"", 0, io.EOF},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		// Test with different chunk sizes so delimiters straddle and land exactly on chunk boundaries:
+		for chunkSize := 1; chunkSize <= len(c.input)+1; chunkSize++ {
+			scanner := NewOptions(strings.NewReader(c.input), len(c.input), &Options{ChunkSize: chunkSize, Delimiter: []byte(c.delim)})
+			i := 0
+			for {
+				line, pos, err := scanner.Line()
+				exp := c.exps[i]
+				eq(exp.line, line)
+				eq(exp.pos, pos)
+				eq(exp.err, err)
+				if err == io.EOF {
+					eq(len(c.exps)-1, i)
+					break
+				}
+				i++
+			}
+		}
+	}
+}
+
+func TestKeepTerminator(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line string
+		pos  int
+		err  error
+	}
+
+	cases := []struct {
+		input string
+		delim string
+		exps  []result
+	}{
+		// \n line endings, final line without terminator
+		{
+			input: "Line1\nLine2\n\nLine3",
+			exps: []result{
+				{"Line3", 13, nil},
+				{"\n", 12, nil},
+				{"Line2\n", 6, nil},
+				{"Line1\n", 0, nil},
+				{"", 0, io.EOF},
+			},
+		},
+		// \r\n line endings, leading empty line
+		{
+			input: "\r\nLine1\r\nLine2\r\n",
+			exps: []result{
+				{"", 16, nil},
+				{"Line2\r\n", 9, nil},
+				{"Line1\r\n", 2, nil},
+				{"\r\n", 0, nil},
+				{"", 0, io.EOF},
+			},
+		},
+		// Multi-byte delimiter
+		{
+			input: "a<>b<>",
+			delim: "<>",
+			exps: []result{
+				{"", 6, nil},
+				{"b<>", 3, nil},
+				{"a<>", 0, nil},
+				{"", 0, io.EOF},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		for _, chunkSize := range []int{1, 2, 3, 5, 100} {
+			scanner := NewString(c.input, &Options{ChunkSize: chunkSize, Delimiter: []byte(c.delim), KeepTerminator: true})
+			i := 0
+			for {
+				line, pos, err := scanner.Line()
+				exp := c.exps[i]
+				eq(exp.line, line)
+				eq(exp.pos, pos)
+				eq(exp.err, err)
+				if err == io.EOF {
+					eq(len(c.exps)-1, i)
+					break
+				}
+				i++
+			}
+		}
+	}
+}
+
+func TestKeepCR(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	for _, keepCR := range []bool{false, true} {
+		scanner := NewString("a\r\nb\r\r\nc\r", &Options{ChunkSize: 2, KeepCR: keepCR})
+		for _, exp := range []string{"c\r", "b\r\r", "a\r"} {
+			if !keepCR {
+				exp = exp[:len(exp)-1]
+			}
+			line, _, err := scanner.Line()
+			eq(exp, line)
+			eq(nil, err)
+		}
+		_, _, err := scanner.Line()
+		eq(io.EOF, err)
+	}
+}
+
+func TestBinary(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line string
+		pos  int
+	}
+
+	input := "\xef\xbb\xbf\x00a\r\n\xff\xfe\x00  \n\x00\r"
+	exps := []result{{"\x00\r", 15}, {"\xff\xfe\x00  ", 7}, {"\xef\xbb\xbf\x00a\r", 0}}
+
+	for _, chunkSize := range []int{1, 2, 3, 100} {
+		scanner := NewString(input, &Options{
+			ChunkSize:         chunkSize,
+			Binary:            true,
+			Encoding:          UTF16LE,
+			StripBOM:          true,
+			TrimSpace:         true,
+			UnicodeLineBreaks: true,
+			SplitMode:         Words,
+		})
+		for _, exp := range exps {
+			line, pos, err := scanner.Line()
+			eq(exp, result{line, pos})
+			eq(nil, err)
+		}
+		_, _, err := scanner.Line()
+		eq(io.EOF, err)
+	}
+}
+
+func TestAutoDetectLineEnding(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	cases := []struct {
+		input    string
+		exps     []string
+		trailing bool
+	}{
+		{"", nil, false},
+		{"a\nb\nc", []string{"c", "b", "a"}, false},
+		{"a\r\nb\r\nc\r\n", []string{"", "c", "b", "a"}, true},
+		{"a\rb\rc\r", []string{"", "c", "b", "a"}, true},
+		{"a\r\nb\rc\rd", []string{"d", "c", "\nb", "a"}, false},
+		{"a\rb\nc\r\nd", []string{"d", "c", "a\rb"}, false},
+	}
+
+	for _, c := range cases {
+		scanner := NewString(c.input, &Options{ChunkSize: 16, AutoDetectLineEnding: true})
+		// Trailing newline is reported according to the detected terminator:
+		eq(c.trailing, scanner.HadTrailingNewline())
+		var lines []string
+		for {
+			line, _, err := scanner.Line()
+			if err != nil {
+				eq(io.EOF, err)
+				break
+			}
+			lines = append(lines, line)
+		}
+		deq(c.exps, lines)
+	}
+
+	// Line ending is detected again after Reset():
+	input := "a\nb"
+	scanner := NewString("a\rb", &Options{ChunkSize: 8, AutoDetectLineEnding: true})
+	line, _, err := scanner.Line()
+	eq("b", line)
+	eq(nil, err)
+	scanner.Reset(strings.NewReader(input), len(input))
+	line, _, err = scanner.Line()
+	eq("b", line)
+	eq(nil, err)
+
+	// Delimiter takes precedence:
+	scanner = NewString("a\rb<>c", &Options{Delimiter: []byte("<>"), AutoDetectLineEnding: true})
+	line, _, err = scanner.Line()
+	eq("c", line)
+	eq(nil, err)
+	line, _, err = scanner.Line()
+	eq("a\rb", line)
+	eq(nil, err)
+}
+
+func TestUnicodeLineBreaks(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line string
+		pos  int
+	}
+
+	input := "a\u2028bc\u2029\u2029d\u0085e\r\nf"
+	exps := []result{{"f", 18}, {"e", 15}, {"d", 12}, {"", 9}, {"bc", 4}, {"a", 0}}
+
+	// Test with all chunk sizes so breaks straddle chunk boundaries:
+	for chunkSize := 1; chunkSize <= len(input); chunkSize++ {
+		scanner := NewString(input, &Options{ChunkSize: chunkSize, UnicodeLineBreaks: true})
+		for _, exp := range exps {
+			line, pos, err := scanner.Line()
+			eq(exp.line, line)
+			eq(exp.pos, pos)
+			eq(nil, err)
+		}
+		_, _, err := scanner.Line()
+		eq(io.EOF, err)
+	}
+
+	// Without the option, only \n is a line terminator:
+	line, _, err := NewString(input, nil).Line()
+	eq("f", line)
+	eq(nil, err)
+	line, _, err = NewString("a\u2028b", nil).Line()
+	eq("a\u2028b", line)
+	eq(nil, err)
+}
+
+func TestVaryingLineLengths(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	var lines []string
+	for i := 1; i <= 40; i++ {
+		lines = append(lines, strings.Repeat(string(rune('a'+i%26)), (i*37)%101))
+	}
+	input := strings.Join(lines, "<>")
+
+	for _, chunkSize := range []int{1, 2, 3, 7, 64, 100, 1000} {
+		for _, maxBufferSize := range []int{101 + 2 + chunkSize, 0} {
+			scanner := NewString(input, &Options{ChunkSize: chunkSize, MaxBufferSize: maxBufferSize, Delimiter: []byte("<>")})
+			pos := len(input)
+			for i := len(lines) - 1; i >= 0; i-- {
+				pos -= len(lines[i])
+				line, linePos, err := scanner.Line()
+				eq(lines[i], line)
+				eq(pos, linePos)
+				eq(nil, err)
+				pos -= 2
+			}
+			_, _, err := scanner.Line()
+			eq(io.EOF, err)
+		}
+	}
+}
+
 func TestLongLine(t *testing.T) {
 	eq := mighty.Eq(t)
 
-	scanner := NewOptions(strings.NewReader("123456789"), 10, &Options{
+	// ChunkSize is capped at MaxBufferSize:
+	scanner := NewOptions(strings.NewReader("123456789"), 9, &Options{
 		MaxBufferSize: 5,
 	})
 
 	_, _, err := scanner.Line()
-	eq(ErrLongLine, err)
-}
+	eq(true, errors.Is(err, ErrLongLine))
+	var lle *LongLineError
+	eq(true, errors.As(err, &lle))
+	eq(LongLineError{MaxBufferSize: 5, MaxLineSize: 5, AttemptedSize: 9, Pos: 4}, *lle)
+	eq("line too long: line at or before position 4 exceeds max buffer size of 5 bytes (attempted 9 bytes)", err.Error())
 
-type fullBufferAndEOFReaderAt struct {
-	content string
+	input := "a\n123456789\nb"
+	scanner = NewOptions(strings.NewReader(input), len(input), &Options{ChunkSize: 4, MaxBufferSize: 8})
+	line, _, err := scanner.Line()
+	eq("b", line)
+	eq(nil, err)
+	_, _, err = scanner.Line()
+	eq(true, errors.As(err, &lle))
+	eq(LongLineError{MaxBufferSize: 8, MaxLineSize: 8, AttemptedSize: 10, Pos: 5}, *lle)
 }
 
-func (r fullBufferAndEOFReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
-	if len(p) == len(r.content) && off == 0 {
-		copy(p, r.content)
-		return len(p), io.EOF
+func TestMaxLineSize(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	cases := []struct {
+		input       string
+		maxLineSize int
+		lines       []string
+		lle         *LongLineError
+	}{
+		{"a\nbbbbb\nc", 0, []string{"c", "bbbbb", "a"}, nil},
+		{"a\nbbbbb\nc", 5, []string{"c", "bbbbb", "a"}, nil},
+		{"a\nbbbbb\r\nc", 5, []string{"c"}, &LongLineError{100, 5, 6, 2}},
+		{"a\nbbbbbbbbbbbbbbbbbbbb\nc", 5, []string{"c"}, &LongLineError{100, 5, 6, 16}},
+		{"bbbbbbbbbbbbbbbbbbbb", 5, nil, &LongLineError{100, 5, 8, 12}},
+		{"bbbbb", 5, []string{"bbbbb"}, nil},
+		{"bbbbb", 200, []string{"bbbbb"}, nil}, // Capped at MaxBufferSize
 	}
-	return strings.NewReader(r.content).ReadAt(p, off)
+
+	for _, c := range cases {
+		scanner := NewOptions(strings.NewReader(c.input), len(c.input), &Options{
+			ChunkSize:     4,
+			MaxBufferSize: 100,
+			MaxLineSize:   c.maxLineSize,
+		})
+		for _, exp := range c.lines {
+			line, _, err := scanner.Line()
+			eq(exp, line)
+			eq(nil, err)
+		}
+		_, _, err := scanner.Line()
+		if c.lle == nil {
+			eq(io.EOF, err)
+			continue
+		}
+		eq(true, errors.Is(err, ErrLongLine))
+		var lle *LongLineError
+		eq(true, errors.As(err, &lle))
+		eq(*c.lle, *lle)
+	}
+
+	err := &LongLineError{MaxBufferSize: 100, MaxLineSize: 5, AttemptedSize: 6, Pos: 2}
+	eq("line too long: line at or before position 2 exceeds max line size of 5 bytes (attempted 6 bytes)", err.Error())
 }
 
-func TestFullBufferAndEOF(t *testing.T) {
+func TestReset(t *testing.T) {
 	eq := mighty.Eq(t)
 
-	in := "1234567890"
-	scanner := NewOptions(fullBufferAndEOFReaderAt{in}, len(in), &Options{
-		MaxBufferSize: len(in),
-	})
-
+	in1, in2 := "a\nb", "c\nd\ne"
+	scanner := NewOptions(strings.NewReader(in1), len(in1), &Options{ChunkSize: 2})
 	line, pos, err := scanner.Line()
+	eq("b", line)
+	eq(2, pos)
 	eq(nil, err)
-	eq(in, line)
-	eq(0, pos)
+
+	scanner.Reset(strings.NewReader(in2), len(in2))
+	eq(2, scanner.o.ChunkSize)
+	for _, exp := range []string{"e", "d", "c"} {
+		line, _, err = scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	_, _, err = scanner.Line()
+	eq(io.EOF, err)
+
+	// Reset after EOF:
+	scanner.Reset(strings.NewReader(in1), len(in1))
+	line, pos, err = scanner.Line()
+	eq("b", line)
+	eq(2, pos)
+	eq(nil, err)
+}
+
+func TestSetReader(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "a\nb\nc\nd"
+	r := strings.NewReader(input)
+	pool := &countingPool{}
+	scanner := NewOptions(r, len(input), &Options{ChunkSize: 2, BufferPool: pool})
+	eq(r, scanner.Reader())
+	for _, exp := range []string{"d", "c", "b", "a"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	_, _, err := scanner.Line()
+	eq(io.EOF, err)
+
+	// Scan a region of the same input, keeping the buffers:
+	arr, puts := scanner.arr, pool.puts
+	r2 := strings.NewReader(input)
+	scanner.SetReader(r2, 5)
+	eq(r2, scanner.Reader())
+	eq(puts, pool.puts)
+	eq(true, &arr[0] == &scanner.arr[0])
+	eq(2, scanner.o.ChunkSize)
+	for _, exp := range []struct {
+		line string
+		pos  int
+	}{{"c", 4}, {"b", 2}, {"a", 0}} {
+		line, pos, err := scanner.Line()
+		eq(exp.line, line)
+		eq(exp.pos, pos)
+		eq(nil, err)
+		eq(true, &arr[0] == &scanner.arr[0])
+	}
+	_, _, err = scanner.Line()
+	eq(io.EOF, err)
+	eq(3, scanner.LineNumber())
+}
+
+func TestScan(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\r\nLine2\n\nLine3"
+	scanner := NewOptions(strings.NewReader(input), len(input), &Options{ChunkSize: 3})
+	for _, exp := range []string{"Line3", "", "Line2", "Line1"} {
+		eq(true, scanner.Scan())
+		eq(exp, scanner.Text())
+		eq(exp, string(scanner.Bytes()))
+	}
+	eq(false, scanner.Scan())
+	eq(nil, scanner.Err())
+
+	scanner = NewOptions(strings.NewReader(input), len(input), &Options{MaxBufferSize: 2})
+	eq(false, scanner.Scan())
+	eq(true, errors.Is(scanner.Err(), ErrLongLine))
+}
+
+func TestLineNumber(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	scanner := NewString("a\nb\n\nc", &Options{ChunkSize: 2})
+	eq(0, scanner.LineNumber())
+	for i := 1; i <= 4; i++ {
+		_, _, err := scanner.Line()
+		eq(nil, err)
+		eq(i, scanner.LineNumber())
+	}
+	_, _, err := scanner.Line()
+	eq(io.EOF, err)
+	eq(4, scanner.LineNumber())
+
+	scanner.Reset(strings.NewReader("x"), 1)
+	eq(0, scanner.LineNumber())
+}
+
+func TestLineContext(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	scanner := NewString("Line1\nLine2\nLine3", &Options{ChunkSize: 12})
+	ctx, cancel := context.WithCancel(context.Background())
+	line, pos, err := scanner.LineContext(ctx)
+	eq("Line3", line)
+	eq(12, pos)
+	eq(nil, err)
+
+	cancel()
+	// Line2 is fully buffered, no read is needed:
+	line, _, err = scanner.LineContext(ctx)
+	eq("Line2", line)
+	eq(nil, err)
+	_, _, err = scanner.LineContext(ctx)
+	eq(context.Canceled, err)
+
+	// Scanner is still usable:
+	line, pos, err = scanner.LineContext(context.Background())
+	eq("Line1", line)
+	eq(0, pos)
+	eq(nil, err)
+}
+
+type fullBufferAndEOFReaderAt struct {
+	content string
+}
+
+func (r fullBufferAndEOFReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if len(p) == len(r.content) && off == 0 {
+		copy(p, r.content)
+		return len(p), io.EOF
+	}
+	return strings.NewReader(r.content).ReadAt(p, off)
+}
+
+func TestFullBufferAndEOF(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	in := "1234567890"
+	scanner := NewOptions(fullBufferAndEOFReaderAt{in}, len(in), &Options{
+		MaxBufferSize: len(in),
+	})
+
+	line, pos, err := scanner.Line()
+	eq(nil, err)
+	eq(in, line)
+	eq(0, pos)
+}
+
+// unexpectedEOFReaderAt returns at most max bytes per ReadAt() call, with
+// io.ErrUnexpectedEOF if less is returned than requested.
+type unexpectedEOFReaderAt struct {
+	content string
+	max     int
+}
+
+func (r unexpectedEOFReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	short := len(p) > r.max
+	if short {
+		p = p[:r.max]
+	}
+	n, err = strings.NewReader(r.content).ReadAt(p, off)
+	if short || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+func TestUnexpectedEOF(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	in := "Line1\nLine2\nLine3"
+	for _, max := range []int{1, 3, 100} {
+		scanner := NewOptions(unexpectedEOFReaderAt{in, max}, len(in), &Options{ChunkSize: 5})
+		for _, exp := range []string{"Line3", "Line2", "Line1"} {
+			line, _, err := scanner.Line()
+			eq(exp, line)
+			eq(nil, err)
+		}
+		_, _, err := scanner.Line()
+		eq(io.EOF, err)
+	}
+
+	// Reading beyond the end is still detected:
+	scanner := NewOptions(unexpectedEOFReaderAt{in, 100}, len(in)+1, nil)
+	_, _, err := scanner.Line()
+	eq(ErrPosBeyondEnd, err)
+}
+
+// shortReaderAt returns at most max bytes per ReadAt() call, without an error.
+type shortReaderAt struct {
+	content string
+	max     int
+}
+
+func (r shortReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if len(p) > r.max {
+		p = p[:r.max]
+	}
+	return strings.NewReader(r.content).ReadAt(p, off)
+}
+
+func TestShortRead(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	in := "Line1\nLine2\nLine3"
+	scanner := NewOptions(shortReaderAt{in, 2}, len(in), &Options{ChunkSize: 7})
+	for _, exp := range []string{"Line3", "Line2", "Line1"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	_, _, err := scanner.Line()
+	eq(io.EOF, err)
+
+	scanner = NewOptions(shortReaderAt{in, 0}, len(in), nil)
+	_, _, err = scanner.Line()
+	eq(true, errors.Is(err, io.ErrNoProgress))
+}
+
+// hugeReaderAt is a virtual input of the given size, having '\n' at the given
+// positions and 'x' everywhere else.
+type hugeReaderAt struct {
+	size     int64
+	newlines []int64
+}
+
+func (r hugeReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	for i := range p {
+		if off+int64(i) >= r.size {
+			return i, io.EOF
+		}
+		p[i] = 'x'
+		for _, nl := range r.newlines {
+			if nl == off+int64(i) {
+				p[i] = '\n'
+			}
+		}
+	}
+	return len(p), nil
+}
+
+func TestNew64(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	const size = 5 << 30 // 5 GB
+	scanner := New64(hugeReaderAt{size, []int64{size - 4, size - 10}}, size, &Options{ChunkSize: 4})
+	line, pos, err := scanner.Line64()
+	eq("xxx", line)
+	eq(int64(size-3), pos)
+	eq(nil, err)
+	lineBytes, pos, err := scanner.LineBytes64()
+	eq("xxxxx", string(lineBytes))
+	eq(int64(size-9), pos)
+	eq(nil, err)
+}
+
+func TestPeek(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	for _, o := range []*Options{{ChunkSize: 1}, {ChunkSize: 3}, {}, {ChunkSize: 2, KeepTerminator: true}} {
+		input := "Line1\r\nLine2\n\nLine3"
+		scanner := NewString(input, o)
+		exps := NewString(input, o)
+		for {
+			expLine, expPos, expErr := exps.Line()
+			for i := 0; i < 2; i++ {
+				line, pos, err := scanner.Peek()
+				eq(expLine, string(line))
+				eq(expPos, pos)
+				eq(expErr, err)
+			}
+			line, pos, err := scanner.Line()
+			eq(expLine, line)
+			eq(expPos, pos)
+			eq(expErr, err)
+			eq(exps.LineNumber(), scanner.LineNumber())
+			if err != nil {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkLongLine(b *testing.B) {
+	input := strings.Repeat("x", 500<<10) + "\nshort\nlines\n"
+	for i := 0; i < b.N; i++ {
+		scanner := NewString(input, &Options{ChunkSize: 1 << 10})
+		for {
+			if _, _, err := scanner.LineBytes(); err != nil {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkShortLines(b *testing.B) {
+	input := strings.Repeat("a short line\n", 100<<10)
+	for i := 0; i < b.N; i++ {
+		scanner := NewString(input, nil)
+		for {
+			if _, _, err := scanner.LineBytes(); err != nil {
+				break
+			}
+		}
+	}
+}
+
+// countingReaderAt counts the ReadAt() calls.
+type countingReaderAt struct {
+	r     io.ReaderAt
+	calls int
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	r.calls++
+	return r.r.ReadAt(p, off)
+}
+
+func TestAdaptiveChunk(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	long := strings.Repeat("x", 1<<16)
+	input := "first\n" + long + "\na\nb"
+	calls := map[bool]int{}
+	for _, adaptive := range []bool{false, true} {
+		r := &countingReaderAt{r: strings.NewReader(input)}
+		scanner := NewOptions(r, len(input), &Options{ChunkSize: 16, MaxBufferSize: 1<<16 + 20, AdaptiveChunk: adaptive})
+		for _, exp := range []string{"b", "a", long, "first"} {
+			line, _, err := scanner.Line()
+			eq(exp, line)
+			eq(nil, err)
+		}
+		_, _, err := scanner.Line()
+		eq(io.EOF, err)
+		calls[adaptive] = r.calls
+	}
+	eq(true, calls[false] > 4096)
+	eq(true, calls[true] < 20)
+}
+
+func TestGrowthChunks(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	long := strings.Repeat("x", 30)
+	input := "ab\n" + long + "\ncd"
+	cases := []struct {
+		o     Options
+		sizes []int
+	}{
+		{Options{}, []int{4, 4, 4, 4, 4, 4, 4, 4, 4}},
+		{Options{GrowthChunks: 1}, []int{4, 4, 4, 4, 4, 4, 4, 4, 4}},
+		{Options{GrowthChunks: 3}, []int{4, 4, 12, 12, 4}},
+		{Options{GrowthChunks: 100}, []int{4, 4, 28}},
+		{Options{GrowthChunks: 100, MaxBufferSize: 32}, []int{4, 4, 27, 1}},
+		{Options{GrowthChunks: 3, MaxBufferSize: 20}, []int{4, 4, 12}},
+		{Options{GrowthChunks: 3, AdaptiveChunk: true}, []int{4, 4, 8, 16, 4}},
+	}
+
+	for _, c := range cases {
+		var sizes []int
+		o := c.o
+		o.ChunkSize = 4
+		o.OnRead = func(offset int64, n int, dur time.Duration, err error) { sizes = append(sizes, n) }
+		scanner := NewString(input, &o)
+		for _, exp := range []string{"cd", long, "ab"} {
+			line, _, err := scanner.Line()
+			if exp == long && o.MaxBufferSize == 20 {
+				eq(true, errors.Is(err, ErrLongLine))
+				break
+			}
+			eq(exp, line)
+			eq(nil, err)
+		}
+		deq(c.sizes, sizes)
+	}
+}
+
+func TestMaxLines(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "a\nb\nc\nd"
+	scanner := NewOptions(strings.NewReader(input), len(input), &Options{MaxLines: 2})
+	for _, exp := range []string{"d", "c"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	_, _, err := scanner.Line()
+	eq(ErrMaxLines, err)
+	_, _, err = scanner.Line()
+	eq(ErrMaxLines, err)
+	eq(false, scanner.Scan())
+	eq(ErrMaxLines, scanner.Err())
+
+	// The count is reset by Reset():
+	scanner.Reset(strings.NewReader(input), len(input))
+	for _, exp := range []string{"d", "c"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	_, _, err = scanner.Line()
+	eq(ErrMaxLines, err)
+}
+
+func TestStats(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\nLine2\nLine3"
+	for _, prefetch := range []bool{false, true} {
+		r := shortReaderAt{content: input, max: 2}
+		scanner := NewOptions(r, len(input), &Options{ChunkSize: 4, Prefetch: prefetch})
+		eq(Stats{}, scanner.Stats())
+		for {
+			if _, _, err := scanner.Line(); err != nil {
+				break
+			}
+		}
+		eq(Stats{BytesRead: int64(len(input)), ReadCalls: 9, LinesReturned: 3}, scanner.Stats())
+		eq(nil, scanner.Close())
+
+		scanner.Reset(r, len(input))
+		eq(Stats{}, scanner.Stats())
+		eq(nil, scanner.Close())
+	}
+}
+
+func TestBytesReadOnce(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	// A full scan must read each byte of the input exactly once:
+	inputs := []string{
+		"",
+		"\n",
+		"Line1\nLine2\nLine3",
+		"Line1\r\n\r\nLine3\r\n",
+		"a\n" + strings.Repeat("long line ", 20) + "\nb\n",
+		"x<br>y<br><br>" + strings.Repeat("z", 30) + "<br",
+	}
+	options := []Options{
+		{},
+		{KeepTerminator: true},
+		{Delimiter: []byte("<br>")},
+		{Delimiter: []byte("<br>"), KeepTerminator: true},
+		{AdaptiveChunk: true},
+		{ShrinkBuffer: true},
+		{Prefetch: true},
+		{SkipEmpty: true, TrimSpace: true},
+		{SplitMode: Words},
+	}
+
+	for _, input := range inputs {
+		for _, o := range options {
+			for chunkSize := 1; chunkSize <= 9; chunkSize++ {
+				o.ChunkSize = chunkSize
+				scanner := NewString(input, &o)
+				for i := 0; ; i++ {
+					if _, _, err := scanner.Line(); err != nil {
+						eq(io.EOF, err)
+						break
+					}
+					// Unread lines are not read again:
+					if i%2 == 0 {
+						eq(nil, scanner.UnreadLine())
+						scanner.Line()
+					}
+				}
+				eq(int64(len(input)), scanner.Stats().BytesRead)
+				eq(nil, scanner.Close())
+			}
+		}
+	}
+}
+
+func BenchmarkFullScan(b *testing.B) {
+	input := strings.Repeat("2006-01-02 15:04:05 INFO some log message\n", 10000)
+	r := strings.NewReader(input)
+	scanner := NewOptions(r, len(input), nil)
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scanner.Reset(r, len(input))
+		for {
+			if _, _, err := scanner.LineBytes(); err != nil {
+				break
+			}
+		}
+		if n := scanner.Stats().BytesRead; n != int64(len(input)) {
+			b.Fatalf("Expected %d bytes read, got: %d", len(input), n)
+		}
+	}
+}
+
+func TestLengthStats(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		min, max, total, count int
+	}
+
+	cases := []struct {
+		input string
+		o     Options
+		exp   result
+	}{
+		{"", Options{}, result{}},
+		{"\n", Options{}, result{0, 0, 0, 1}},
+		{"ab\nc\ndefg", Options{}, result{1, 4, 7, 3}},
+		{"ab\nc\ndefg\n", Options{}, result{0, 4, 7, 4}},
+		{"ab\nc\ndefg\n", Options{SkipEmpty: true}, result{1, 4, 7, 3}},
+		{"ab\nc\ndefg\n", Options{KeepTerminator: true}, result{0, 5, 10, 4}},
+	}
+
+	for _, c := range cases {
+		for chunkSize := 1; chunkSize <= 4; chunkSize++ {
+			o := c.o
+			o.ChunkSize = chunkSize
+			scanner := NewString(c.input, &o)
+			// Peeked and unread lines must only be counted once:
+			scanner.Peek()
+			for {
+				if _, _, err := scanner.Line(); err != nil {
+					break
+				}
+				scanner.UnreadLine()
+				scanner.Line()
+			}
+			min, max, total, count := scanner.LengthStats()
+			eq(c.exp, result{min, max, total, count})
+
+			scanner.Reset(strings.NewReader(c.input), len(c.input))
+			min, max, total, count = scanner.LengthStats()
+			eq(result{}, result{min, max, total, count})
+		}
+	}
+}
+
+func TestPosOffset(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	type result struct {
+		line     string
+		pos, end int
+	}
+
+	input := "head\nLine1\nLine2\nLine3"
+	section := io.NewSectionReader(strings.NewReader(input), 5, int64(len(input)-5))
+	exps := []result{{"Line3", 17, 22}, {"Line2", 11, 16}, {"Line1", 5, 10}}
+
+	for chunkSize := 1; chunkSize <= 8; chunkSize++ {
+		scanner := NewOptions(section, int(section.Size()), &Options{ChunkSize: chunkSize, PosOffset: 5})
+		eq(22, scanner.Pos())
+		for _, exp := range exps {
+			line, pos, end, err := scanner.LineBytesRange()
+			eq(exp, result{string(line), pos, end})
+			eq(nil, err)
+			eq(true, scanner.Pos() <= pos)
+		}
+		_, pos, err := scanner.LineBytes()
+		eq(io.EOF, err)
+		eq(0, pos)
+		eq(5, scanner.Pos())
+
+		line, pos, err := scanner.FindLastBefore(17, func(line []byte) bool { return len(line) > 0 })
+		eq("Line2", line)
+		eq(11, pos)
+		eq(nil, err)
+	}
+
+	// Positions in errors:
+	scanner := NewOptions(section, int(section.Size()), &Options{ChunkSize: 4, MaxBufferSize: 4, PosOffset: 5})
+	_, _, err := scanner.Line()
+	var lle *LongLineError
+	eq(true, errors.As(err, &lle))
+	eq(int64(18), lle.Pos)
+
+	r := &flakyReaderAt{r: strings.NewReader(input), n: 1}
+	scanner = NewOptions(r, len(input), &Options{ChunkSize: 4, PosOffset: 100})
+	_, _, err = scanner.Line()
+	deq(&ReadError{Pos: 118, Err: errFlaky}, err)
+}
+
+func TestMaxBytesRead(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\nLine2\nLine3"
+	cases := []struct {
+		maxBytesRead int64
+		exps         []string
+		err          error
+	}{
+		{0, []string{"Line3", "Line2", "Line1"}, io.EOF},
+		{int64(len(input)), []string{"Line3", "Line2", "Line1"}, io.EOF},
+		{int64(len(input)) - 1, []string{"Line3", "Line2"}, ErrReadBudgetExceeded},
+		{8, []string{"Line3"}, ErrReadBudgetExceeded},
+		{3, nil, ErrReadBudgetExceeded},
+	}
+
+	for _, c := range cases {
+		for _, prefetch := range []bool{false, true} {
+			scanner := NewString(input, &Options{ChunkSize: 4, MaxBytesRead: c.maxBytesRead, Prefetch: prefetch})
+			for _, exp := range c.exps {
+				line, _, err := scanner.Line()
+				eq(exp, line)
+				eq(nil, err)
+			}
+			_, _, err := scanner.Line()
+			eq(c.err, err)
+			_, _, err = scanner.Line()
+			eq(c.err, err)
+			eq(true, scanner.Stats().BytesRead <= int64(len(input)))
+
+			// The budget is renewed by Reset():
+			scanner.Reset(strings.NewReader(input), len(input))
+			if c.maxBytesRead == 0 || c.maxBytesRead >= 4 {
+				_, _, err = scanner.Line()
+				eq(nil, err)
+			}
+			eq(nil, scanner.Close())
+		}
+	}
+}
+
+func TestMinPos(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line      string
+		pos       int
+		truncated bool
+	}
+
+	input := "Line1\nLine2\nLine3"
+	cases := []struct {
+		minPos int
+		exps   []result
+	}{
+		{0, []result{{"Line3", 12, false}, {"Line2", 6, false}, {"Line1", 0, false}}},
+		{6, []result{{"Line3", 12, false}, {"Line2", 6, false}}},
+		{5, []result{{"Line3", 12, false}, {"Line2", 6, false}}},
+		{8, []result{{"Line3", 12, false}, {"ne2", 8, true}}},
+		{14, []result{{"ne3", 14, true}}},
+		{17, nil},
+		{100, nil},
+	}
+
+	for _, c := range cases {
+		for _, chunkSize := range []int{1, 2, 100} {
+			scanner := NewOptions(strings.NewReader(input), len(input), &Options{ChunkSize: chunkSize, MinPos: c.minPos})
+			eq(false, scanner.Truncated())
+			for _, exp := range c.exps {
+				line, pos, err := scanner.Line()
+				eq(exp, result{line, pos, scanner.Truncated()})
+				eq(nil, err)
+			}
+			_, _, err := scanner.Line()
+			eq(io.EOF, err)
+		}
+	}
+
+	// Delimiter right before MinPos:
+	input = "a<>b<>c"
+	scanner := NewString(input, &Options{Delimiter: []byte("<>"), MinPos: 3})
+	for _, exp := range []string{"c", "b"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+		eq(false, scanner.Truncated())
+	}
+	scanner = NewString(input, &Options{Delimiter: []byte("<>"), MinPos: 2})
+	scanner.Line()
+	line, _, err := scanner.Line()
+	eq(">b", line)
+	eq(nil, err)
+	eq(true, scanner.Truncated())
+}
+
+// wrappedEOFReaderAt reports io.EOF wrapped.
+type wrappedEOFReaderAt struct {
+	r io.ReaderAt
+}
+
+func (r wrappedEOFReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	n, err = r.r.ReadAt(p, off)
+	if err == io.EOF {
+		err = fmt.Errorf("wrapped: %w", err)
+	}
+	return
+}
+
+func TestEOF(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	// Reading the first chunk fully with a (wrapped) EOF:
+	input := "Line1\nLine2"
+	scanner := New(wrappedEOFReaderAt{strings.NewReader(input + "\n")}, len(input)+1)
+	for _, exp := range []string{"", "Line2", "Line1"} {
+		eq(false, scanner.AtEOF())
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	eq(true, scanner.AtEOF())
+	_, _, err := scanner.Line()
+	eq(io.EOF, err)
+	eq(true, scanner.AtEOF())
+
+	// A wrapped EOF when reading less is not the end of scanning:
+	scanner = New(wrappedEOFReaderAt{strings.NewReader(input)}, len(input)+1)
+	_, _, err = scanner.Line()
+	eq(ErrPosBeyondEnd, err)
+	eq(false, scanner.AtEOF())
+
+	// Read failures are reported as *ReadError:
+	scanner = New(&flakyReaderAt{r: strings.NewReader(input), n: 1}, len(input))
+	_, _, err = scanner.Line()
+	var re *ReadError
+	eq(true, errors.As(err, &re))
+	eq(int64(0), re.Pos)
+	eq(errFlaky, re.Err)
+	eq("read failed at position 0: flaky", err.Error())
+	eq(false, scanner.AtEOF())
+}
+
+// flakyReaderAt fails every ReadAt() call whose number is divisible by n.
+type flakyReaderAt struct {
+	r     io.ReaderAt
+	n     int
+	calls int
+}
+
+var errFlaky = errors.New("flaky")
+
+func (r *flakyReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if r.calls++; r.calls%r.n == 0 {
+		return 0, errFlaky
+	}
+	return r.r.ReadAt(p, off)
+}
+
+func TestReadRetry(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	input := "Line1\nLine2\nLine3"
+	var attempts []int
+	scanner := NewOptions(&flakyReaderAt{r: strings.NewReader(input), n: 2}, len(input), &Options{
+		ChunkSize: 4,
+		ReadRetry: func(err error, attempt int) bool {
+			eq(errFlaky, err)
+			attempts = append(attempts, attempt)
+			return true
+		},
+	})
+	for _, exp := range []string{"Line3", "Line2", "Line1"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	_, _, err := scanner.Line()
+	eq(io.EOF, err)
+	deq([]int{1, 1, 1, 1}, attempts)
+
+	// Giving up:
+	scanner = NewOptions(&flakyReaderAt{r: strings.NewReader(input), n: 1}, len(input), &Options{
+		ReadRetry: func(err error, attempt int) bool {
+			return attempt < 3
+		},
+	})
+	_, _, err = scanner.Line()
+	eq(true, errors.Is(err, errFlaky))
+	eq(3, scanner.Stats().ReadCalls)
+}
+
+func TestPosBuffered(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\nLine2\nLine3"
+	scanner := NewOptions(strings.NewReader(input), len(input), &Options{ChunkSize: 4})
+	eq(17, scanner.Pos())
+	eq(0, scanner.Buffered())
+
+	type state struct{ pos, buffered int }
+	for _, exp := range []state{{9, 2}, {5, 0}, {0, 0}} {
+		_, _, err := scanner.Line()
+		eq(nil, err)
+		eq(exp, state{scanner.Pos(), scanner.Buffered()})
+	}
+}
+
+func TestOnProgress(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	input := "Line1\nLine2\nLine3"
+	var positions []int
+	scanner := NewOptions(strings.NewReader(input), len(input), &Options{
+		ChunkSize:  5,
+		OnProgress: func(pos int) { positions = append(positions, pos) },
+	})
+	for {
+		if _, _, err := scanner.Line(); err != nil {
+			eq(io.EOF, err)
+			break
+		}
+	}
+	deq([]int{12, 7, 2, 0}, positions)
+}
+
+func TestOnRead(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	type read struct {
+		offset int64
+		n      int
+		err    error
+	}
+
+	input := "Line1\nLine2\nLine3"
+	var reads []read
+	scanner := NewOptions(strings.NewReader(input), len(input), &Options{
+		ChunkSize: 8,
+		OnRead: func(offset int64, n int, dur time.Duration, err error) {
+			eq(true, dur >= 0)
+			reads = append(reads, read{offset, n, err})
+		},
+	})
+	for {
+		if _, _, err := scanner.Line(); err != nil {
+			eq(io.EOF, err)
+			break
+		}
+	}
+	deq([]read{{9, 8, nil}, {1, 8, nil}, {0, 1, nil}}, reads)
+
+	// Failed reads are also reported:
+	reads = nil
+	scanner = NewOptions(&flakyReaderAt{r: strings.NewReader(input), n: 1}, len(input), &Options{
+		ChunkSize: 8,
+		OnRead: func(offset int64, n int, dur time.Duration, err error) {
+			reads = append(reads, read{offset, n, err})
+		},
+	})
+	_, _, err := scanner.Line()
+	deq(&ReadError{Pos: 9, Err: errFlaky}, err)
+	deq([]read{{9, 0, err}}, reads)
+}
+
+func TestLineBytesRange(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line       string
+		start, end int
+	}
+
+	input := "Line1\r\n\nLine2\nLine3"
+	cases := []struct {
+		o    *Options
+		exps []result
+	}{
+		{nil, []result{{"Line3", 14, 19}, {"Line2", 8, 13}, {"", 7, 7}, {"Line1", 0, 5}}},
+		{&Options{KeepCR: true}, []result{{"Line3", 14, 19}, {"Line2", 8, 13}, {"", 7, 7}, {"Line1\r", 0, 6}}},
+		{&Options{KeepTerminator: true}, []result{{"Line3", 14, 19}, {"Line2\n", 8, 14}, {"\n", 7, 8}, {"Line1\r\n", 0, 7}}},
+		{&Options{SplitMode: Words}, []result{{"Line3", 14, 19}, {"Line2", 8, 13}, {"Line1", 0, 5}}},
+	}
+
+	for _, c := range cases {
+		scanner := NewOptions(strings.NewReader(input), len(input), c.o)
+		for _, exp := range c.exps {
+			line, start, end, err := scanner.LineBytesRange()
+			eq(exp, result{string(line), start, end})
+			eq(nil, err)
+		}
+		_, _, _, err := scanner.LineBytesRange()
+		eq(io.EOF, err)
+	}
+}
+
+func TestLineBytesTerm(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line string
+		pos  int
+		term string
+	}
+
+	input := "a\r\nb\nc\rd\r\n"
+	cases := []struct {
+		o    Options
+		exps []result
+	}{
+		{Options{}, []result{{"", 10, ""}, {"c\rd", 5, "\r\n"}, {"b", 3, "\n"}, {"a", 0, "\r\n"}}},
+		{Options{KeepCR: true}, []result{{"", 10, ""}, {"c\rd\r", 5, "\n"}, {"b", 3, "\n"}, {"a\r", 0, "\n"}}},
+		{Options{KeepTerminator: true}, []result{{"", 10, ""}, {"c\rd\r\n", 5, "\n"}, {"b\n", 3, "\n"}, {"a\r\n", 0, "\n"}}},
+		{Options{Delimiter: []byte("\r\n")}, []result{{"", 10, ""}, {"b\nc\rd", 3, "\r\n"}, {"a", 0, "\r\n"}}},
+		{Options{SkipEmpty: true}, []result{{"c\rd", 5, "\r\n"}, {"b", 3, "\n"}, {"a", 0, "\r\n"}}},
+		{Options{SplitMode: Words}, []result{{"d", 7, ""}, {"c", 5, ""}, {"b", 3, ""}, {"a", 0, ""}}},
+	}
+
+	for _, c := range cases {
+		for _, chunkSize := range []int{1, 2, 3, 100} {
+			c.o.ChunkSize = chunkSize
+			scanner := NewString(input, &c.o)
+			for i, exp := range c.exps {
+				if i == 1 {
+					// Unread lines must be returned with the same terminator:
+					_, _, err := scanner.Peek()
+					eq(nil, err)
+				}
+				line, pos, term, err := scanner.LineBytesTerm()
+				eq(exp, result{string(line), pos, string(term)})
+				eq(nil, err)
+			}
+			_, _, _, err := scanner.LineBytesTerm()
+			eq(io.EOF, err)
+		}
+	}
+}
+
+func TestHadTrailingNewline(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	cases := []struct {
+		input string
+		o     *Options
+		exp   bool
+	}{
+		{"", nil, false},
+		{"\n", nil, true},
+		{"a\nb", nil, false},
+		{"a\nb\n", nil, true},
+		{"a\r\n", nil, true},
+		{"a;", &Options{Separator: ';'}, true},
+		{"a\n", &Options{Delimiter: []byte("<END>")}, false},
+		{"a<END>", &Options{Delimiter: []byte("<END>")}, true},
+		{"\n", &Options{MinPos: 1}, false},
+	}
+
+	for _, c := range cases {
+		scanner := NewOptions(strings.NewReader(c.input), len(c.input), c.o)
+		eq(c.exp, scanner.HadTrailingNewline())
+		// Scanning is not affected:
+		_, _, err := scanner.Line()
+		eq(c.exp, scanner.HadTrailingNewline())
+		if c.input != "" && (c.o == nil || c.o.MinPos == 0) {
+			eq(nil, err)
+		}
+	}
+}
+
+func TestSkipEmpty(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line string
+		pos  int
+	}
+
+	cases := []struct {
+		input string
+		exps  []result
+	}{
+		{"", nil},
+		{"\n\n\n", nil},
+		{"a\n\nb\n\n", []result{{"b", 3}, {"a", 0}}},
+		{"\n\r\na\r\n\r\n", []result{{"a", 3}}},
+	}
+
+	for _, c := range cases {
+		scanner := NewOptions(strings.NewReader(c.input), len(c.input), &Options{ChunkSize: 2, SkipEmpty: true})
+		for i, exp := range c.exps {
+			line, pos, err := scanner.Line()
+			eq(exp, result{line, pos})
+			eq(nil, err)
+			eq(i+1, scanner.LineNumber())
+		}
+		_, _, err := scanner.Line()
+		eq(io.EOF, err)
+	}
+}
+
+func TestCollapseBlankLines(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line string
+		pos  int
+	}
+
+	cases := []struct {
+		input     string
+		trimSpace bool
+		exps      []result
+	}{
+		{"", false, nil},
+		{"a\n\n\n\nb\n", false, []result{{"", 7}, {"b", 5}, {"", 4}, {"a", 0}}},
+		{"\n\na\n \n\t\n", false, []result{{"", 8}, {"\t", 6}, {" ", 4}, {"a", 2}, {"", 1}}},
+		{"\n\na\n \n\t\n", true, []result{{"", 8}, {"a", 2}, {"", 1}}},
+	}
+
+	for _, c := range cases {
+		scanner := NewOptions(strings.NewReader(c.input), len(c.input),
+			&Options{ChunkSize: 2, CollapseBlankLines: true, TrimSpace: c.trimSpace})
+		for i, exp := range c.exps {
+			line, pos, err := scanner.Line()
+			eq(exp, result{line, pos})
+			eq(nil, err)
+			eq(i+1, scanner.LineNumber())
+		}
+		_, _, err := scanner.Line()
+		eq(io.EOF, err)
+	}
+
+	// A peeked blank line must not be collapsed:
+	scanner := NewOptions(strings.NewReader("a\n\n\n"), 4, &Options{CollapseBlankLines: true})
+	line, pos, err := scanner.Line()
+	eq("", line)
+	eq(4, pos)
+	eq(nil, err)
+	eq(nil, scanner.UnreadLine())
+	line, pos, err = scanner.Line()
+	eq("", line)
+	eq(4, pos)
+	eq(nil, err)
+	line, pos, err = scanner.Line()
+	eq("a", line)
+	eq(0, pos)
+	eq(nil, err)
+}
+
+func TestUnique(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line string
+		pos  int
+	}
+
+	cases := []struct {
+		input     string
+		trimSpace bool
+		exps      []result
+	}{
+		{"", false, nil},
+		{"a\nb\nb\nb\na\n\n", false, []result{{"", 11}, {"a", 8}, {"b", 6}, {"a", 0}}},
+		{"a\na \n a\nb", true, []result{{"b", 8}, {"a", 5}}},
+		{"a\na \n a\nb", false, []result{{"b", 8}, {" a", 5}, {"a ", 2}, {"a", 0}}},
+	}
+
+	for _, c := range cases {
+		scanner := NewOptions(strings.NewReader(c.input), len(c.input),
+			&Options{ChunkSize: 2, Unique: true, TrimSpace: c.trimSpace})
+		for i, exp := range c.exps {
+			line, pos, err := scanner.Line()
+			eq(exp, result{line, pos})
+			eq(nil, err)
+			eq(i+1, scanner.LineNumber())
+		}
+		_, _, err := scanner.Line()
+		eq(io.EOF, err)
+	}
+
+	// A peeked line must not be skipped:
+	scanner := NewString("a\na\nb", &Options{Unique: true})
+	line, _, err := scanner.Line()
+	eq("b", line)
+	eq(nil, err)
+	peeked, _, err := scanner.Peek()
+	eq("a", string(peeked))
+	eq(nil, err)
+	line, pos, err := scanner.Line()
+	eq("a", line)
+	eq(2, pos)
+	eq(nil, err)
+	_, _, err = scanner.Line()
+	eq(io.EOF, err)
+}
+
+func TestTransform(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	// stripColors removes ANSI color escape sequences in place:
+	stripColors := func(line []byte) []byte {
+		out := line[:0]
+		for i := 0; i < len(line); i++ {
+			if line[i] == 0x1b && i+1 < len(line) && line[i+1] == '[' {
+				for i += 2; i < len(line) && line[i] != 'm'; i++ {
+				}
+				continue
+			}
+			out = append(out, line[i])
+		}
+		return out
+	}
+
+	type result struct {
+		line string
+		pos  int
+	}
+
+	cases := []struct {
+		input string
+		o     Options
+		exps  []result
+	}{
+		{"", Options{Transform: bytes.ToUpper}, nil},
+		{"a\nBc\n", Options{Transform: bytes.ToUpper}, []result{{"", 5}, {"BC", 2}, {"A", 0}}},
+		{" a\n b ", Options{Transform: bytes.ToUpper, TrimSpace: true}, []result{{"B", 3}, {"A", 0}}},
+		{"\x1b[31merr\x1b[0m\nok\n\x1b[1m\x1b[0m", Options{Transform: stripColors}, []result{{"", 16}, {"ok", 13}, {"err", 0}}},
+		{"\x1b[31merr\x1b[0m\nok\n\x1b[1m\x1b[0m", Options{Transform: stripColors, SkipEmpty: true}, []result{{"ok", 13}, {"err", 0}}},
+		{"A\na\n", Options{Transform: bytes.ToLower, Unique: true}, []result{{"", 4}, {"a", 2}}},
+	}
+
+	for _, c := range cases {
+		for chunkSize := 1; chunkSize <= 8; chunkSize++ {
+			o := c.o
+			o.ChunkSize = chunkSize
+			scanner := NewString(c.input, &o)
+			for _, exp := range c.exps {
+				line, pos, err := scanner.Line()
+				eq(exp, result{line, pos})
+				eq(nil, err)
+			}
+			_, _, err := scanner.Line()
+			eq(io.EOF, err)
+		}
+	}
+
+	// Lines returned again are transformed again, not the transformed data:
+	inc := func(line []byte) []byte {
+		for i := range line {
+			line[i]++
+		}
+		return line
+	}
+	input := []byte("abc\ndef")
+	scanner := NewBytes(input, &Options{Transform: inc})
+	line, _, err := scanner.Peek()
+	eq("efg", string(line))
+	eq(nil, err)
+	for i := 0; i < 2; i++ {
+		line, pos, err := scanner.Line()
+		eq("efg", line)
+		eq(4, pos)
+		eq(nil, err)
+		if i == 0 {
+			eq(nil, scanner.UnreadLine())
+		}
+	}
+	m := scanner.Mark()
+	for i := 0; i < 2; i++ {
+		line, _, err := scanner.Line()
+		eq("bcd", line)
+		eq(nil, err)
+		eq(nil, scanner.Rewind(m))
+	}
+	eq("abc\ndef", string(input))
+}
+
+func TestHash(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\nLine2\nLine3"
+	h := fnv.New64a()
+	scanner := NewOptions(strings.NewReader(input), len(input), &Options{ChunkSize: 2, Hash: h})
+
+	_, _, err := scanner.Peek()
+	eq(nil, err)
+	for unread := false; ; {
+		if _, _, err = scanner.Line(); err != nil {
+			break
+		}
+		if scanner.LineNumber() == 2 && !unread {
+			eq(nil, scanner.UnreadLine())
+			unread = true
+		}
+	}
+	eq(io.EOF, err)
+
+	exp := fnv.New64a()
+	exp.Write([]byte("Line3Line2Line1"))
+	eq(exp.Sum64(), h.Sum64())
+}
+
+func TestTrimSpace(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line string
+		pos  int
+	}
+
+	input := "  a \n \t\n\tb\r\n"
+	cases := []struct {
+		skipEmpty bool
+		exps      []result
+	}{
+		{false, []result{{"", 12}, {"b", 8}, {"", 5}, {"a", 0}}},
+		{true, []result{{"b", 8}, {"a", 0}}},
+	}
+
+	for _, c := range cases {
+		scanner := NewOptions(strings.NewReader(input), len(input), &Options{TrimSpace: true, SkipEmpty: c.skipEmpty})
+		for _, exp := range c.exps {
+			line, pos, err := scanner.Line()
+			eq(exp, result{line, pos})
+			eq(nil, err)
+		}
+		_, _, err := scanner.Line()
+		eq(io.EOF, err)
+	}
+}
+
+func TestUnreadLine(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\r\nLine2\nLine3"
+	for _, keep := range []bool{false, true} {
+		scanner := NewOptions(strings.NewReader(input), len(input), &Options{ChunkSize: 3, KeepTerminator: keep})
+		eq(ErrInvalidUnread, scanner.UnreadLine())
+
+		exps := []string{"Line3", "Line2", "Line1"}
+		if keep {
+			exps = []string{"Line3", "Line2\n", "Line1\r\n"}
+		}
+		for i, exp := range exps {
+			line, _, err := scanner.Line()
+			eq(exp, line)
+			eq(nil, err)
+			eq(nil, scanner.UnreadLine())
+			eq(ErrInvalidUnread, scanner.UnreadLine())
+			eq(i, scanner.LineNumber())
+
+			line2, _, err := scanner.Line()
+			eq(line, line2)
+			eq(nil, err)
+		}
+		_, _, err := scanner.Line()
+		eq(io.EOF, err)
+		eq(ErrInvalidUnread, scanner.UnreadLine())
+	}
+}
+
+func TestShrinkBuffer(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	long := strings.Repeat("x", 1<<16)
+	input := strings.Repeat("short\n", 100) + long + "\na\nb"
+	for _, shrink := range []bool{false, true} {
+		scanner := NewOptions(strings.NewReader(input), len(input), &Options{ChunkSize: 64, ShrinkBuffer: shrink})
+		for _, exp := range []string{"b", "a", long} {
+			line, _, err := scanner.Line()
+			eq(exp, line)
+			eq(nil, err)
+		}
+		eq(true, len(scanner.arr) >= len(long))
+		for i := 0; i < 100; i++ {
+			line, _, err := scanner.Line()
+			eq("short", line)
+			eq(nil, err)
+		}
+		_, _, err := scanner.Line()
+		eq(io.EOF, err)
+		eq(!shrink, len(scanner.arr) >= len(long))
+	}
+
+	// The buffers are obtained from and returned to BufferPool:
+	pool := &listPool{}
+	scanner := NewOptions(strings.NewReader(input), len(input), &Options{ChunkSize: 64, ShrinkBuffer: true, BufferPool: pool})
+	lines, _, err := scanner.Lines(3)
+	eq(3, len(lines))
+	eq(nil, err)
+	gets := pool.gets
+	lines, _, err = scanner.Lines(100)
+	eq(100, len(lines))
+	eq(nil, err)
+	eq(true, len(scanner.arr) < len(long))
+	eq(gets+1, pool.gets)
+	returned := false
+	for _, b := range pool.bufs {
+		returned = returned || cap(b) >= len(long)
+	}
+	eq(true, returned)
+}
+
+// listPool is a BufferPool which keeps all returned buffers, and counts the calls.
+type listPool struct {
+	bufs       [][]byte
+	gets, puts int
+}
+
+func (lp *listPool) Get() (b []byte) {
+	lp.gets++
+	if n := len(lp.bufs); n > 0 {
+		b, lp.bufs = lp.bufs[n-1], lp.bufs[:n-1]
+	}
+	return b
+}
+
+func (lp *listPool) Put(b []byte) {
+	lp.puts++
+	lp.bufs = append(lp.bufs, b)
+}
+
+func TestInitialBufferSize(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\nLine2\nLine3"
+	scanner := NewOptions(strings.NewReader(input), len(input), &Options{ChunkSize: 4, InitialBufferSize: 100})
+	for _, exp := range []string{"Line3", "Line2", "Line1"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+		eq(100, len(scanner.arr))
+	}
+
+	// Capped at MaxBufferSize:
+	scanner = NewOptions(strings.NewReader(input), len(input), &Options{ChunkSize: 4, MaxBufferSize: 10, InitialBufferSize: 100})
+	line, _, err := scanner.Line()
+	eq("Line3", line)
+	eq(nil, err)
+	eq(10, len(scanner.arr))
 }
@@ -0,0 +1,249 @@
+package backscanner
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"sync"
+)
+
+// ErrBGZF indicates that the input is not a valid BGZF file.
+var ErrBGZF = errors.New("invalid BGZF block")
+
+// ErrGzipTrailer indicates that the input is too short to hold a gzip member
+// with its trailer.
+var ErrGzipTrailer = errors.New("input too short for gzip trailer")
+
+// gzipTrailerSize is the size of the trailer of gzip members.
+const gzipTrailerSize = 8
+
+// gzipMinSize is the min size of gzip members: the header and the trailer
+// (the compressed data is not checked).
+const gzipMinSize = 10 + gzipTrailerSize
+
+// GzipTrailer reads the trailer of the last gzip member of the input of the
+// given size (its last 8 bytes), and returns the CRC-32 and the size (modulo
+// 2^32) of the member's uncompressed data, without decompressing anything.
+//
+// The data is not validated (a trailer does not identify itself), so the
+// result is only meaningful if the input is known to be gzip data.
+// ErrGzipTrailer is returned if size is less than the size of the smallest
+// gzip member.
+func GzipTrailer(r io.ReaderAt, size int64) (crc, isize uint32, err error) {
+	if size < gzipMinSize {
+		return 0, 0, ErrGzipTrailer
+	}
+	var trailer [gzipTrailerSize]byte
+	if n, err := r.ReadAt(trailer[:], size-gzipTrailerSize); n < len(trailer) {
+		if err == nil || err == io.EOF {
+			err = ErrPosBeyondEnd
+		}
+		return 0, 0, fmt.Errorf("failed to read gzip trailer: %w", err)
+	}
+	return binary.LittleEndian.Uint32(trailer[:]), binary.LittleEndian.Uint32(trailer[4:]), nil
+}
+
+// BGZFIndexEntry is an entry of a BGZF index: the start of a block.
+type BGZFIndexEntry struct {
+	Compressed   int64 // Compressed is the offset of the block in the compressed file
+	Uncompressed int64 // Uncompressed is the offset of the block's data in the uncompressed data
+}
+
+// BGZFIndex is the index of a BGZF (block gzip) file, as stored in .gzi files.
+// Entries are sorted by their offsets.
+type BGZFIndex []BGZFIndexEntry
+
+// ReadBGZFIndex reads a BGZF index in the .gzi format (as created by bgzip -i).
+func ReadBGZFIndex(r io.Reader) (BGZFIndex, error) {
+	var count uint64
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	// The first block is not listed:
+	index := BGZFIndex{{0, 0}}
+	for i := uint64(0); i < count; i++ {
+		var offsets [2]uint64
+		if err := binary.Read(r, binary.LittleEndian, &offsets); err != nil {
+			return nil, fmt.Errorf("failed to read index: %w", err)
+		}
+		index = append(index, BGZFIndexEntry{int64(offsets[0]), int64(offsets[1])})
+	}
+	return index, nil
+}
+
+// NewBGZF returns a new Scanner that scans the uncompressed content of a BGZF
+// (block gzip) file, starting at its end, with the given Options (which may be
+// nil). Returned positions are positions in the uncompressed content.
+//
+// Blocks are located using the index: only the blocks holding the scanned
+// data are read and decompressed (blocks not listed in the index are found by
+// walking the blocks following the preceding listed one).
+// Close() closes r if it implements io.Closer.
+func NewBGZF(r io.ReaderAt, index BGZFIndex, o *Options) (*Scanner, error) {
+	b := &bgzfReaderAt{r: r, index: index}
+	if len(b.index) == 0 || b.index[0] != (BGZFIndexEntry{}) {
+		b.index = append(BGZFIndex{{}}, b.index...)
+	}
+
+	// Size of the uncompressed data: walk the blocks after the last entry:
+	last := b.index[len(b.index)-1]
+	coff := last.Compressed
+	b.size = last.Uncompressed
+	for {
+		bsize, isize, err := b.blockInfo(coff)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		coff, b.size = coff+int64(bsize), b.size+int64(isize)
+	}
+
+	return New64(b, b.size, o), nil
+}
+
+// bgzfReaderAt is an io.ReaderAt over the uncompressed content of a BGZF file.
+type bgzfReaderAt struct {
+	r     io.ReaderAt
+	index BGZFIndex
+	size  int64 // size is the size of the uncompressed data
+
+	mu     sync.Mutex // mu protects the cached block
+	block  []byte     // block is the data of the last decompressed block
+	ublock int64      // ublock is the uncompressed offset of block
+	cdata  []byte     // cdata is a buffer for the compressed data
+}
+
+// bgzfHeaderSize is the size of the header of BGZF blocks (with the
+// minimal extra field), which we read to find the size of blocks.
+const bgzfHeaderSize = 18
+
+// blockInfo returns the size of the block at the given compressed offset,
+// and the size of its uncompressed data. io.EOF is returned at the end of
+// the file.
+func (b *bgzfReaderAt) blockInfo(coff int64) (bsize, isize int, err error) {
+	var header [bgzfHeaderSize]byte
+	n, err := b.r.ReadAt(header[:], coff)
+	if n == 0 && err == io.EOF {
+		return 0, 0, io.EOF
+	}
+	if n < len(header) {
+		return 0, 0, ErrBGZF
+	}
+	xlen := int(binary.LittleEndian.Uint16(header[10:]))
+	if header[0] != 31 || header[1] != 139 || header[2] != 8 || header[3]&4 == 0 || xlen < 6 {
+		return 0, 0, ErrBGZF
+	}
+
+	extra := make([]byte, xlen)
+	if n, _ = b.r.ReadAt(extra, coff+12); n < len(extra) {
+		return 0, 0, ErrBGZF
+	}
+	// Find the BC subfield holding the block size:
+	for len(extra) >= 4 {
+		slen := int(binary.LittleEndian.Uint16(extra[2:]))
+		if extra[0] == 'B' && extra[1] == 'C' && slen == 2 && len(extra) >= 6 {
+			bsize = int(binary.LittleEndian.Uint16(extra[4:])) + 1
+			break
+		}
+		if len(extra) < 4+slen {
+			break
+		}
+		extra = extra[4+slen:]
+	}
+	if bsize < 12+xlen+8 {
+		return 0, 0, ErrBGZF
+	}
+
+	var isizeBuf [4]byte
+	if n, _ = b.r.ReadAt(isizeBuf[:], coff+int64(bsize)-4); n < len(isizeBuf) {
+		return 0, 0, ErrBGZF
+	}
+	return bsize, int(binary.LittleEndian.Uint32(isizeBuf[:])), nil
+}
+
+// load decompresses the block at the given compressed offset into b.block.
+func (b *bgzfReaderAt) load(coff int64, bsize, isize int) error {
+	if cap(b.cdata) < bsize {
+		b.cdata = make([]byte, bsize)
+	}
+	data := b.cdata[:bsize]
+	if n, _ := b.r.ReadAt(data, coff); n < len(data) {
+		return ErrBGZF
+	}
+	xlen := int(binary.LittleEndian.Uint16(data[10:]))
+	cdata := data[12+xlen : bsize-8]
+
+	if cap(b.block) < isize {
+		b.block = make([]byte, isize)
+	}
+	b.block = b.block[:isize]
+	fr := flate.NewReader(bytes.NewReader(cdata))
+	defer fr.Close()
+	if _, err := io.ReadFull(fr, b.block); err != nil {
+		b.block = b.block[:0]
+		return fmt.Errorf("failed to decompress block: %w", err)
+	}
+	if crc32.ChecksumIEEE(b.block) != binary.LittleEndian.Uint32(data[bsize-8:]) {
+		b.block = b.block[:0]
+		return ErrBGZF
+	}
+	return nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (b *bgzfReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for len(p) > 0 {
+		if off < 0 || off >= b.size {
+			return n, io.EOF
+		}
+		if off < b.ublock || off >= b.ublock+int64(len(b.block)) {
+			if err = b.find(off); err != nil {
+				return n, err
+			}
+		}
+		m := copy(p, b.block[off-b.ublock:])
+		n, p, off = n+m, p[m:], off+int64(m)
+	}
+	return n, nil
+}
+
+// find loads the block containing the given uncompressed offset.
+func (b *bgzfReaderAt) find(off int64) error {
+	i := sort.Search(len(b.index), func(i int) bool { return b.index[i].Uncompressed > off }) - 1
+	coff, uoff := b.index[i].Compressed, b.index[i].Uncompressed
+	for {
+		bsize, isize, err := b.blockInfo(coff)
+		if err == io.EOF {
+			return ErrBGZF // off is within size, so there must be a block
+		}
+		if err != nil {
+			return err
+		}
+		if off < uoff+int64(isize) {
+			if err = b.load(coff, bsize, isize); err != nil {
+				return err
+			}
+			b.ublock = uoff
+			return nil
+		}
+		coff, uoff = coff+int64(bsize), uoff+int64(isize)
+	}
+}
+
+// Close closes the underlying input if it implements io.Closer.
+func (b *bgzfReaderAt) Close() error {
+	if c, ok := b.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package backscanner
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/icza/mighty"
+)
+
+// bgzfCompress compresses data into BGZF blocks holding at most blockSize bytes
+// each (plus an empty EOF block), and returns the compressed data and the
+// .gzi index.
+func bgzfCompress(data []byte, blockSize int) (compressed, gzi []byte) {
+	buf := &bytes.Buffer{}
+	var entries [][2]uint64
+	writeBlock := func(block []byte) {
+		cdata := &bytes.Buffer{}
+		fw, _ := flate.NewWriter(cdata, flate.DefaultCompression)
+		fw.Write(block)
+		fw.Close()
+
+		header := []byte{31, 139, 8, 4, 0, 0, 0, 0, 0, 255, 6, 0, 'B', 'C', 2, 0, 0, 0}
+		binary.LittleEndian.PutUint16(header[16:], uint16(len(header)+cdata.Len()+8-1))
+		buf.Write(header)
+		buf.Write(cdata.Bytes())
+		binary.Write(buf, binary.LittleEndian, crc32.ChecksumIEEE(block))
+		binary.Write(buf, binary.LittleEndian, uint32(len(block)))
+	}
+	for uoff := 0; uoff < len(data); uoff += blockSize {
+		if uoff > 0 {
+			entries = append(entries, [2]uint64{uint64(buf.Len()), uint64(uoff)})
+		}
+		end := uoff + blockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		writeBlock(data[uoff:end])
+	}
+	writeBlock(nil) // EOF block
+
+	idx := &bytes.Buffer{}
+	binary.Write(idx, binary.LittleEndian, uint64(len(entries)))
+	binary.Write(idx, binary.LittleEndian, entries)
+	return buf.Bytes(), idx.Bytes()
+}
+
+func TestGzipTrailer(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	// Concatenated gzip members:
+	var data []byte
+	for _, member := range []string{"first member\n", strings.Repeat("second member\n", 100)} {
+		buf := &bytes.Buffer{}
+		w := gzip.NewWriter(buf)
+		w.Write([]byte(member))
+		eq(nil, w.Close())
+		data = append(data, buf.Bytes()...)
+
+		crc, isize, err := GzipTrailer(bytes.NewReader(data), int64(len(data)))
+		eq(nil, err)
+		eq(crc32.ChecksumIEEE([]byte(member)), crc)
+		eq(uint32(len(member)), isize)
+	}
+
+	_, _, err := GzipTrailer(bytes.NewReader(data), gzipMinSize-1)
+	eq(ErrGzipTrailer, err)
+	_, _, err = GzipTrailer(bytes.NewReader(data), int64(len(data))+1)
+	eq(true, errors.Is(err, ErrPosBeyondEnd))
+}
+
+func TestNewBGZF(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprint("Line", i, strings.Repeat("x", i%17)))
+	}
+	content := strings.Join(lines, "\n")
+	compressed, gzi := bgzfCompress([]byte(content), 100)
+
+	// The compressed data is valid gzip:
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	eq(nil, err)
+	all, err := ioutil.ReadAll(zr)
+	eq(nil, err)
+	eq(content, string(all))
+
+	index, err := ReadBGZFIndex(bytes.NewReader(gzi))
+	eq(nil, err)
+	eq(true, len(index) > len(content)/100)
+
+	// Full and sparse index:
+	for _, index := range []BGZFIndex{index, index[:len(index)/2], nil} {
+		scanner, err := NewBGZF(bytes.NewReader(compressed), index, &Options{ChunkSize: 64})
+		eq(nil, err)
+		pos := len(content) + 1
+		for i := len(lines) - 1; i >= 0; i-- {
+			pos -= len(lines[i]) + 1
+			line, linePos, err := scanner.Line()
+			eq(lines[i], line)
+			eq(pos, linePos)
+			eq(nil, err)
+		}
+		_, _, err = scanner.Line()
+		eq(io.EOF, err)
+		eq(nil, scanner.Close())
+	}
+
+	_, err = NewBGZF(strings.NewReader("not a bgzf file"), nil, nil)
+	eq(ErrBGZF, err)
+
+	_, err = ReadBGZFIndex(strings.NewReader("x"))
+	eq(true, err != nil)
+}
@@ -0,0 +1,109 @@
+package backscanner
+
+import (
+	"io"
+	"sync"
+)
+
+// Line is a line delivered by Scanner.Channel().
+type Line struct {
+	Text string // Text is the line
+	Pos  int    // Pos is the absolute byte-position of the line
+	Err  error  // Err is the error encountered if not nil, in which case Text and Pos are not set
+}
+
+// Channel starts a goroutine which scans the remaining lines and delivers them
+// on the returned channel with the given buffer size. The channel is closed
+// when the end of the input is reached, or after an error is delivered (in
+// the Err field of a Line).
+//
+// The returned function stops the goroutine early; it returns after the
+// goroutine exited, after which the Scanner may be used (or closed) again:
+// it continues with the first line not delivered.
+// The Scanner must not be used while the goroutine is running.
+// The input is not closed by the goroutine, call Scanner.Close() if needed.
+func (s *Scanner) Channel(bufSize int) (<-chan Line, func()) {
+	ch := make(chan Line, bufSize)
+	stopCh, done := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	stop := func() {
+		once.Do(func() { close(stopCh) })
+		<-done
+	}
+
+	go func() {
+		defer close(done)
+		defer close(ch)
+		for {
+			var l Line
+			l.Text, l.Pos, l.Err = s.Line()
+			if l.Err == io.EOF {
+				return
+			}
+			select {
+			case ch <- l:
+			case <-stopCh:
+				// The line is not delivered, so it is not consumed:
+				s.UnreadLine()
+				return
+			}
+			if l.Err != nil {
+				return
+			}
+		}
+	}()
+
+	return ch, stop
+}
+
+// BatchChannel is like Channel(), but it delivers the lines in batches of
+// batchSize lines (in the order they are scanned): each batch is a newly
+// allocated slice, so it may be retained by the receiver. A partial batch is
+// delivered when the end of the input is reached or an error is encountered.
+// batchSize is treated as 1 if it's not positive.
+//
+// The channel is closed when the end of the input is reached or after an
+// error is encountered, in which case the error is reported by Scanner.Err()
+// after the returned function returns. Lines of a batch not delivered due to
+// stopping the goroutine early are consumed, the Scanner continues with the
+// first line after them.
+func (s *Scanner) BatchChannel(batchSize, bufSize int) (<-chan []string, func()) {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	ch := make(chan []string, bufSize)
+	stopCh, done := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	stop := func() {
+		once.Do(func() { close(stopCh) })
+		<-done
+	}
+
+	go func() {
+		defer close(done)
+		defer close(ch)
+		for {
+			batch := make([]string, 0, batchSize)
+			var err error
+			for len(batch) < batchSize {
+				var line string
+				if line, _, err = s.Line(); err != nil {
+					break
+				}
+				batch = append(batch, line)
+			}
+			if len(batch) > 0 {
+				select {
+				case ch <- batch:
+				case <-stopCh:
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return ch, stop
+}
@@ -0,0 +1,119 @@
+package backscanner
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/icza/mighty"
+)
+
+func TestChannel(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\nLine2\nLine3"
+	for _, bufSize := range []int{0, 1, 10} {
+		ch, stop := NewString(input, &Options{ChunkSize: 3}).Channel(bufSize)
+		var lines []Line
+		for l := range ch {
+			lines = append(lines, l)
+		}
+		stop()
+		eq(3, len(lines))
+		for i, exp := range []Line{{"Line3", 12, nil}, {"Line2", 6, nil}, {"Line1", 0, nil}} {
+			eq(exp, lines[i])
+		}
+	}
+
+	// Error:
+	ch, stop := NewString(input, &Options{MaxBufferSize: 2}).Channel(0)
+	l := <-ch
+	eq(true, errors.Is(l.Err, ErrLongLine))
+	_, ok := <-ch
+	eq(false, ok)
+	stop()
+
+	// Early stop:
+	scanner := NewString(input, nil)
+	ch, stop = scanner.Channel(0)
+	eq(Line{"Line3", 12, nil}, <-ch)
+	stop()
+	stop()
+	// The Scanner continues with the first line not delivered:
+	line, pos, err := scanner.Line()
+	eq("Line2", line)
+	eq(6, pos)
+	eq(nil, err)
+	_, _, err = scanner.Line()
+	eq(nil, err)
+	_, _, err = scanner.Line()
+	eq(io.EOF, err)
+	eq(nil, scanner.Close())
+}
+
+func TestBatchChannel(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	input := "Line1\nLine2\nLine3\nLine4\nLine5"
+	cases := []struct {
+		batchSize int
+		exps      [][]string
+	}{
+		{0, [][]string{{"Line5"}, {"Line4"}, {"Line3"}, {"Line2"}, {"Line1"}}},
+		{1, [][]string{{"Line5"}, {"Line4"}, {"Line3"}, {"Line2"}, {"Line1"}}},
+		{2, [][]string{{"Line5", "Line4"}, {"Line3", "Line2"}, {"Line1"}}},
+		{5, [][]string{{"Line5", "Line4", "Line3", "Line2", "Line1"}}},
+		{10, [][]string{{"Line5", "Line4", "Line3", "Line2", "Line1"}}},
+	}
+
+	for _, c := range cases {
+		for _, bufSize := range []int{0, 1, 10} {
+			scanner := NewString(input, &Options{ChunkSize: 3})
+			ch, stop := scanner.BatchChannel(c.batchSize, bufSize)
+			var batches [][]string
+			for batch := range ch {
+				batches = append(batches, batch)
+			}
+			stop()
+			deq(c.exps, batches)
+			eq(nil, scanner.Err())
+		}
+	}
+
+	// Batches are retained by the receiver:
+	ch, stop := NewString(input, nil).BatchChannel(2, 0)
+	b1, b2 := <-ch, <-ch
+	deq([]string{"Line5", "Line4"}, b1)
+	deq([]string{"Line3", "Line2"}, b2)
+	stop()
+
+	// Error:
+	scanner := NewString("a\nb\n"+input, &Options{MaxBufferSize: 4})
+	ch, stop = scanner.BatchChannel(2, 0)
+	var batches [][]string
+	for batch := range ch {
+		batches = append(batches, batch)
+	}
+	stop()
+	deq([][]string(nil), batches)
+	eq(true, errors.Is(scanner.Err(), ErrLongLine))
+
+	scanner = NewString(input+"\nb\na", &Options{ChunkSize: 2, MaxBufferSize: 4})
+	ch, stop = scanner.BatchChannel(3, 0)
+	deq([]string{"a", "b"}, <-ch)
+	_, ok := <-ch
+	eq(false, ok)
+	stop()
+	eq(true, errors.Is(scanner.Err(), ErrLongLine))
+
+	// Early stop:
+	scanner = NewString(input, nil)
+	ch, stop = scanner.BatchChannel(2, 0)
+	deq([]string{"Line5", "Line4"}, <-ch)
+	stop()
+	stop()
+	line, _, err := scanner.Line()
+	// The undelivered batch is consumed:
+	eq("Line1", line)
+	eq(nil, err)
+}
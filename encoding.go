@@ -0,0 +1,130 @@
+package backscanner
+
+import (
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the character encoding of the input.
+type Encoding int
+
+// Supported encodings.
+const (
+	// UTF8 is the default encoding: input is processed as raw bytes.
+	UTF8 Encoding = iota
+
+	// UTF16LE is UTF-16, little-endian.
+	UTF16LE
+
+	// UTF16BE is UTF-16, big-endian.
+	UTF16BE
+)
+
+// Transformer transforms bytes, and it is implemented by the transformers and
+// decoders of golang.org/x/text (it has the same method set as
+// golang.org/x/text/transform.Transformer), so those can be used without this
+// package depending on golang.org/x/text.
+type Transformer interface {
+	// Transform writes to dst the transformed bytes read from src, and
+	// returns the number of dst bytes written and src bytes read.
+	Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error)
+
+	// Reset resets the state and allows a Transformer to be reused.
+	Reset()
+}
+
+// transform transforms src using t, appending the result to dst.
+// t is reset first, and src is treated as the complete input.
+func transform(t Transformer, dst, src []byte) ([]byte, error) {
+	t.Reset()
+	for {
+		if cap(dst)-len(dst) < 4*len(src)+utf8.UTFMax {
+			// Grow dst to have room for the worst-case (plus some):
+			dst = append(dst[:cap(dst)], make([]byte, 4*len(src)+utf8.UTFMax)...)[:len(dst)]
+		}
+		nDst, nSrc, err := t.Transform(dst[len(dst):cap(dst)], src, true)
+		dst, src = dst[:len(dst)+nDst], src[nSrc:]
+		if err == nil {
+			return dst, nil
+		}
+		if nDst == 0 && nSrc == 0 {
+			// No progress although we had enough room in dst:
+			return dst, err
+		}
+		// Progress was made, which may be due to a short dst, retry with the rest.
+	}
+}
+
+// bom returns the byte order mark of the encoding.
+func (e Encoding) bom() []byte {
+	switch e {
+	case UTF16LE:
+		return []byte{0xff, 0xfe}
+	case UTF16BE:
+		return []byte{0xfe, 0xff}
+	}
+	return []byte{0xef, 0xbb, 0xbf}
+}
+
+// crLen returns the length of the encoded terminal '\r' of data,
+// or 0 if data does not end with '\r'.
+func (e Encoding) crLen(data []byte) int {
+	n := len(data)
+	switch {
+	case e == UTF8 && n >= 1 && data[n-1] == '\r':
+		return 1
+	case e == UTF16LE && n >= 2 && data[n-2] == '\r' && data[n-1] == 0:
+		return 2
+	case e == UTF16BE && n >= 2 && data[n-2] == 0 && data[n-1] == '\r':
+		return 2
+	}
+	return 0
+}
+
+// encode returns the encoded form of the given UTF-8 text.
+func (e Encoding) encode(s string) []byte {
+	if e == UTF8 {
+		return []byte(s)
+	}
+
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		if e == UTF16LE {
+			b = append(b, byte(u), byte(u>>8))
+		} else {
+			b = append(b, byte(u>>8), byte(u))
+		}
+	}
+	return b
+}
+
+// decodeUTF16 decodes UTF-16 encoded data, appending the UTF-8 encoded
+// result to dst. A trailing odd byte is decoded as utf8.RuneError.
+func (e Encoding) decodeUTF16(dst, data []byte) []byte {
+	unit := func(i int) rune {
+		if e == UTF16LE {
+			return rune(data[i]) | rune(data[i+1])<<8
+		}
+		return rune(data[i])<<8 | rune(data[i+1])
+	}
+
+	var rb [utf8.UTFMax]byte
+	for i := 0; i < len(data); i += 2 {
+		r := utf8.RuneError
+		if i+1 < len(data) {
+			r = unit(i)
+			if utf16.IsSurrogate(r) {
+				r2 := utf8.RuneError
+				if i+3 < len(data) {
+					r2 = unit(i + 2)
+				}
+				if r = utf16.DecodeRune(r, r2); r != utf8.RuneError {
+					i += 2
+				}
+			}
+		}
+		n := utf8.EncodeRune(rb[:], r)
+		dst = append(dst, rb[:n]...)
+	}
+	return dst
+}
@@ -0,0 +1,111 @@
+package backscanner
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/icza/mighty"
+)
+
+func TestEncodingUTF16(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line string
+		pos  int
+	}
+
+	for _, enc := range []Encoding{UTF16LE, UTF16BE} {
+		// ੁĀ encoded in UTF-16LE contains 0x0a 0x00 at an odd position
+		input := append(enc.bom(), enc.encode("Line1\r\nੁĀ\n\n😀é\r\nLast")...)
+		exps := []result{{"Last", 34}, {"😀é", 24}, {"", 22}, {"ੁĀ", 16}, {"Line1", 0}}
+
+		for chunkSize := 1; chunkSize <= len(input); chunkSize++ {
+			scanner := NewBytes(input, &Options{ChunkSize: chunkSize, Encoding: enc})
+			for _, exp := range exps {
+				line, pos, err := scanner.Line()
+				eq(exp.line, line)
+				eq(exp.pos, pos)
+				eq(nil, err)
+			}
+			_, _, err := scanner.Line()
+			eq(io.EOF, err)
+		}
+	}
+
+	// Unicode line breaks are also encoded:
+	input := UTF16LE.encode("a\u2028b")
+	line, pos, err := NewBytes(input, &Options{Encoding: UTF16LE, UnicodeLineBreaks: true}).Line()
+	eq("b", line)
+	eq(4, pos)
+	eq(nil, err)
+}
+
+func TestStripBOM(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	for _, strip := range []bool{false, true} {
+		scanner := NewString("\ufeffLine1\n\ufeffLine2", &Options{ChunkSize: 2, StripBOM: strip})
+		line, _, err := scanner.Line()
+		eq("\ufeffLine2", line) // Not at position 0
+		eq(nil, err)
+		line, pos, err := scanner.Line()
+		if strip {
+			eq("Line1", line)
+		} else {
+			eq("\ufeffLine1", line)
+		}
+		eq(0, pos)
+		eq(nil, err)
+	}
+}
+
+var errShortDst = errors.New("short destination buffer")
+
+// latin1Decoder decodes ISO-8859-1 encoded text.
+type latin1Decoder struct{}
+
+func (latin1Decoder) Reset() {}
+
+func (latin1Decoder) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for _, b := range src {
+		if b == 0xff {
+			return nDst, nSrc, errors.New("0xff is not allowed")
+		}
+		if len(dst)-nDst < utf8.RuneLen(rune(b)) {
+			return nDst, nSrc, errShortDst
+		}
+		nDst += utf8.EncodeRune(dst[nDst:], rune(b))
+		nSrc++
+	}
+	return
+}
+
+func TestDecoder(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := []byte("Caf\xe9\r\n\xc9t\xe9\n\xff")
+	scanner := NewBytes(input, &Options{ChunkSize: 3, Decoder: latin1Decoder{}})
+	_, pos, err := scanner.Line()
+	eq(10, pos)
+	eq("0xff is not allowed", err.Error())
+	for _, exp := range []string{"Été", "Café"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	_, _, err = scanner.Line()
+	eq(io.EOF, err)
+
+	// Long lines that do not fit into the initial dst:
+	long := make([]byte, 1000)
+	for i := range long {
+		long[i] = 0xe9
+	}
+	line, _, err := NewBytes(long, &Options{Decoder: latin1Decoder{}}).Line()
+	eq(nil, err)
+	eq(2000, len(line))
+	eq(1000, utf8.RuneCountInString(line))
+}
@@ -0,0 +1,91 @@
+package backscanner
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// followInterval is the interval TailFollow() polls the size of the input at.
+var followInterval = 100 * time.Millisecond
+
+// TailFollow follows the growth of an input whose initial size is initialSize
+// (like tail -f), and delivers the lines appended to it in forward order on the
+// returned channel. Lines are delivered once they are terminated by a '\n'.
+// If initialSize is in the middle of a line, the rest of that line is
+// delivered as the first line.
+//
+// poll is called periodically to get the current size of the input, e.g. by
+// calling Stat() on a file. The appended region is scanned backward, so only
+// new data is read. If the size decreases (e.g. the file is truncated), the
+// new size is taken as the starting point.
+//
+// The returned function stops following; the channel is closed when following
+// stops, which also happens if reading the input fails.
+func TailFollow(r io.ReaderAt, initialSize int64, poll func() int64) (<-chan string, func()) {
+	ch := make(chan string)
+	stopCh := make(chan struct{})
+	var once sync.Once
+	stop := func() {
+		once.Do(func() { close(stopCh) })
+	}
+
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(followInterval)
+		defer ticker.Stop()
+
+		done := initialSize // done is the position up to which lines are delivered
+		var lines []string
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+			}
+
+			size := poll()
+			if size < done {
+				done = size
+			}
+			if size == done {
+				continue
+			}
+
+			// The last line is incomplete (or empty if the data ends with
+			// a terminator), it is delivered when it's complete:
+			scanner := NewSection(r, done, size-done, nil)
+			_, newDone, err := scanner.LineBytes64()
+			if err != nil {
+				return
+			}
+			lines = lines[:0]
+			for first := newDone; ; {
+				line, pos, err := scanner.Line64()
+				if err != nil {
+					if err != io.EOF {
+						return
+					}
+					if first > done {
+						// An empty line at done is not returned by the Scanner:
+						lines = append(lines, "")
+					}
+					break
+				}
+				lines = append(lines, line)
+				first = pos
+			}
+
+			for i := len(lines) - 1; i >= 0; i-- {
+				select {
+				case ch <- lines[i]:
+				case <-stopCh:
+					return
+				}
+			}
+			done = newDone
+		}
+	}()
+
+	return ch, stop
+}
@@ -0,0 +1,70 @@
+package backscanner
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/icza/mighty"
+)
+
+// growingReaderAt is an io.ReaderAt whose content may be appended to concurrently.
+type growingReaderAt struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (r *growingReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return bytes.NewReader(r.data).ReadAt(p, off)
+}
+
+func (r *growingReaderAt) append(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data = append(r.data, s...)
+}
+
+func (r *growingReaderAt) size() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return int64(len(r.data))
+}
+
+func TestTailFollow(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	defer func(d time.Duration) { followInterval = d }(followInterval)
+	followInterval = time.Millisecond
+
+	r := &growingReaderAt{data: []byte("old1\nold2\npar")}
+	ch, stop := TailFollow(r, r.size(), r.size)
+
+	next := func() string {
+		select {
+		case line := <-ch:
+			return line
+		case <-time.After(time.Second):
+			return "<timeout>"
+		}
+	}
+
+	r.append("tial\n")
+	eq("tial", next())
+	r.append("a\nb\n\nc")
+	eq("a", next())
+	eq("b", next())
+	eq("", next())
+	r.append("\n\n")
+	eq("c", next())
+	eq("", next())
+	r.append("d\n")
+	eq("d", next())
+
+	stop()
+	stop()
+	for range ch {
+	}
+}
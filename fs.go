@@ -0,0 +1,39 @@
+//go:build go1.16
+// +build go1.16
+
+package backscanner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// NewFS opens the named file of fsys and returns a new Scanner positioned at its
+// end, with the given Options (which may be nil).
+//
+// If the opened file implements io.ReaderAt (like files of os.DirFS and
+// embed.FS), it is read directly, and it is closed when Scanner.Close() is
+// called. Else the file is read fully into memory (and closed).
+func NewFS(fsys fs.FS, name string, o *Options) (*Scanner, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if ra, ok := f.(io.ReaderAt); ok {
+		return NewOptions(ra, int(fi.Size()), o), nil
+	}
+
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return NewOptions(bytes.NewReader(data), len(data), o), nil
+}
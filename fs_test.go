@@ -0,0 +1,45 @@
+//go:build go1.16
+// +build go1.16
+
+package backscanner
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/icza/mighty"
+)
+
+// noReaderAtFS wraps an fs.FS, hiding the io.ReaderAt implementation of its files.
+type noReaderAtFS struct {
+	fs.FS
+}
+
+func (f noReaderAtFS) Open(name string) (fs.File, error) {
+	file, err := f.FS.Open(name)
+	return struct{ fs.File }{file}, err
+}
+
+func TestNewFS(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	fsys := fstest.MapFS{"dir/test.log": &fstest.MapFile{Data: []byte("Line1\nLine2\nLine3")}}
+	for _, fsys := range []fs.FS{fsys, noReaderAtFS{fsys}} {
+		scanner, err := NewFS(fsys, "dir/test.log", &Options{ChunkSize: 4})
+		eq(nil, err)
+		for _, exp := range []string{"Line3", "Line2", "Line1"} {
+			line, _, err := scanner.Line()
+			eq(exp, line)
+			eq(nil, err)
+		}
+		_, _, err = scanner.Line()
+		eq(io.EOF, err)
+		eq(nil, scanner.Close())
+
+		_, err = NewFS(fsys, "missing.log", nil)
+		eq(true, errors.Is(err, fs.ErrNotExist))
+	}
+}
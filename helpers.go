@@ -0,0 +1,486 @@
+package backscanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+var (
+	// ErrStopScan may be returned by the function passed to ForEachLine()
+	// to stop scanning without an error
+	ErrStopScan = errors.New("stop scan")
+)
+
+// ReadLastN reads up to n lines from the input and returns them in forward
+// (chronological) order, along with the absolute byte-position of the first
+// returned line.
+// If the input has less than n lines, all lines are returned without error.
+func (s *Scanner) ReadLastN(n int) (lines []string, pos int, err error) {
+	for len(lines) < n {
+		line, linePos, err := s.LineBytes()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, 0, err
+		}
+		lines = append(lines, string(line))
+		pos = linePos
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines, pos, nil
+}
+
+// TailForward returns the last n lines of the input in forward (chronological)
+// order, like the tail command: the last line of the input is the last
+// element. Unlike with ReadLastN(), if the input ends with a terminator at the
+// starting position, the empty line after it is not included (unless
+// SkipEmpty is set, which skips it anyway). If the input has less than n
+// lines, all lines are returned without error.
+//
+// Lines are collected by scanning backward, so only the end of the input is
+// read. TailForward should be called before reading lines from the Scanner.
+func (s *Scanner) TailForward(n int) ([]string, error) {
+	if n > 0 && s.lines == 0 && !s.o.SkipEmpty && s.HadTrailingNewline() {
+		if _, _, err := s.LineBytes(); err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+	lines, _, err := s.ReadLastN(n)
+	return lines, err
+}
+
+// Lines returns up to n lines and their absolute byte-positions in the order
+// they are scanned (reverse order). If the end of the input is reached before
+// n lines, the available lines are returned without error, and io.EOF is only
+// returned if there are no more lines. If another error occurs, the lines
+// read before it are returned along with the error.
+func (s *Scanner) Lines(n int) (lines []string, positions []int, err error) {
+	for len(lines) < n {
+		line, pos, err := s.Line()
+		if err != nil {
+			if err == io.EOF && len(lines) > 0 {
+				break
+			}
+			return lines, positions, err
+		}
+		lines, positions = append(lines, line), append(positions, pos)
+	}
+	return lines, positions, nil
+}
+
+// Skip skips up to n lines without converting them to strings, and returns the
+// number of lines actually skipped. If the end of the input is reached before
+// skipping n lines, skipped is less than n, and err is nil.
+func (s *Scanner) Skip(n int) (skipped int, err error) {
+	for ; skipped < n; skipped++ {
+		if _, _, err = s.LineBytes(); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+	}
+	return
+}
+
+// Discard discards up to n lines, and returns the number of lines actually
+// discarded. Unlike Skip(), it returns io.EOF if the end of the input is reached
+// before discarding n lines. Lines are only inspected in the internal buffer,
+// they are not copied.
+func (s *Scanner) Discard(n int) (discarded int, err error) {
+	for ; discarded < n; discarded++ {
+		if _, _, err = s.LineBytes(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// LineAt scans backward nFromEnd lines and returns the last one scanned, so 1
+// returns the next line (the last line of the input if no lines were read
+// yet), 2 the line before it, and so on. Values less than 1 are treated as 1.
+// The Scanner is left positioned before the returned line. io.EOF is returned
+// if the input has less than nFromEnd remaining lines.
+//
+// Like with TailForward(), if no lines were read yet and the input ends with a
+// terminator at the starting position, the empty line after it is not counted,
+// so the last line is the one before the terminator.
+func (s *Scanner) LineAt(nFromEnd int) (line string, pos int, err error) {
+	if s.lines == 0 && !s.o.SkipEmpty && s.HadTrailingNewline() {
+		if _, _, err = s.LineBytes(); err != nil {
+			return "", 0, err
+		}
+	}
+	if _, err = s.Discard(nFromEnd - 1); err != nil {
+		return "", 0, err
+	}
+	return s.Line()
+}
+
+// ForEachLine calls fn with each remaining line and its absolute
+// byte-position, until the end of the input is reached or fn returns a non-nil
+// error. If fn returns ErrStopScan, scanning stops and nil is returned; other
+// errors returned by fn are returned as-is. Reaching the end of the input is
+// not an error.
+// The line passed to fn shares data with the internal buffer of the Scanner,
+// just like lines returned by LineBytes().
+func (s *Scanner) ForEachLine(fn func(line []byte, pos int) error) error {
+	for {
+		line, pos, err := s.LineBytes()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err = fn(line, pos); err != nil {
+			if err == ErrStopScan {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// FindLast returns the next line (previous in the source) that contains needle,
+// and its absolute byte-position.
+// If no such line is found, io.EOF is returned.
+func (s *Scanner) FindLast(needle []byte) (line string, pos int, err error) {
+	return s.FindLastFunc(func(line []byte) bool {
+		return bytes.Contains(line, needle)
+	})
+}
+
+// FindLastFunc returns the next line (previous in the source) for which pred
+// returns true, and its absolute byte-position.
+// The line passed to pred shares data with the internal buffer of the Scanner,
+// just like lines returned by LineBytes().
+// If no such line is found, io.EOF is returned.
+func (s *Scanner) FindLastFunc(pred func(line []byte) bool) (line string, pos int, err error) {
+	for {
+		var lineBytes []byte
+		if lineBytes, pos, err = s.LineBytes(); err != nil {
+			return "", 0, err
+		}
+		if pred(lineBytes) {
+			return string(lineBytes), pos, nil
+		}
+	}
+}
+
+// FindLastBefore is like FindLastFunc(), but it searches the input before the
+// given position: the Scanner is repositioned to pos first (like SetReader()
+// with the same input), and scanning may be continued after the found line.
+// If pos is the position of a line (e.g. one found earlier), the lines before
+// it are searched; else the line containing pos is cut at pos.
+// pos is a position reported by the Scanner, including PosOffset.
+func (s *Scanner) FindLastBefore(pos int, pred func(line []byte) bool) (line string, linePos int, err error) {
+	s.SetReader(s.r, pos-s.o.PosOffset)
+	return s.FindLastFunc(pred)
+}
+
+// FindLastWithContext is like FindLast(), but it also returns context lines
+// (like grep -B and -A): contextLines is the block of lines around the match
+// in forward (chronological) order: up to before lines preceding the match in
+// the input, the match itself, and up to after lines following it. Since lines
+// are scanned backward, the lines after the match are the ones scanned right
+// before it, and the lines before it are scanned after the match, so scanning
+// continues before them.
+// If no matching line is found, io.EOF is returned.
+func (s *Scanner) FindLastWithContext(needle []byte, before, after int) (match string, contextLines []string, pos int, err error) {
+	var afters []string // lines following the current line in the input, in scan order
+	for {
+		var line []byte
+		if line, pos, err = s.LineBytes(); err != nil {
+			return "", nil, 0, err
+		}
+		if bytes.Contains(line, needle) {
+			match = string(line)
+			break
+		}
+		if after > 0 {
+			if len(afters) == after {
+				afters = append(afters[:0], afters[1:]...)
+			}
+			afters = append(afters, string(line))
+		}
+	}
+
+	contextLines = append(contextLines, match)
+	for len(contextLines) <= before {
+		line, _, err := s.LineBytes()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", nil, 0, err
+		}
+		contextLines = append(contextLines, string(line))
+	}
+	for i, j := 0, len(contextLines)-1; i < j; i, j = i+1, j-1 {
+		contextLines[i], contextLines[j] = contextLines[j], contextLines[i]
+	}
+	for i := len(afters) - 1; i >= 0; i-- {
+		contextLines = append(contextLines, afters[i])
+	}
+	return match, contextLines, pos, nil
+}
+
+// FindLastRegexp returns the next line (previous in the source) that matches re,
+// its absolute byte-position, and the text of the leftmost match and its
+// submatches (as returned by regexp.Regexp.FindSubmatch()).
+// If no such line is found, io.EOF is returned.
+func (s *Scanner) FindLastRegexp(re *regexp.Regexp) (line string, pos int, submatches []string, err error) {
+	for {
+		var lineBytes []byte
+		if lineBytes, pos, err = s.LineBytes(); err != nil {
+			return "", 0, nil, err
+		}
+		if matches := re.FindSubmatch(lineBytes); matches != nil {
+			submatches = make([]string, len(matches))
+			for i, m := range matches {
+				submatches[i] = string(m)
+			}
+			return string(lineBytes), pos, submatches, nil
+		}
+	}
+}
+
+// WriteTo implements io.WriterTo. It writes all remaining lines to w (in the
+// order they are returned by LineBytes()), each followed by a '\n' (unless
+// Options.KeepTerminator is set, in which case lines are written as-is).
+// It returns the number of bytes written, and a nil error when the end of the
+// input is reached.
+func (s *Scanner) WriteTo(w io.Writer) (n int64, err error) {
+	newline := []byte{'\n'}
+	for {
+		line, _, err := s.LineBytes()
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return n, err
+		}
+		m, err := w.Write(line)
+		n += int64(m)
+		if err == nil && !s.o.KeepTerminator {
+			m, err = w.Write(newline)
+			n += int64(m)
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// ForwardFrom returns a bufio.Scanner that reads the input forward, starting
+// at pos (a position reported by the Scanner, e.g. the position of a found
+// line), up to the starting position of the Scanner. The returned scanner
+// splits lines using bufio.ScanLines, its Split() method may be used to split
+// otherwise (e.g. if Separator or Delimiter is set).
+//
+// ForwardFrom reads the input of the Scanner directly, the state of the
+// Scanner is not changed, so it may also be used further.
+// An error is returned if pos is before the start of the input.
+func (s *Scanner) ForwardFrom(pos int) (*bufio.Scanner, error) {
+	start := int64(pos - s.o.PosOffset)
+	if start < 0 {
+		return nil, fmt.Errorf("invalid position: %d (before the start of the input)", pos)
+	}
+	if start > s.start {
+		start = s.start
+	}
+	return bufio.NewScanner(io.NewSectionReader(s.r, start, s.start-start)), nil
+}
+
+// SearchPos searches for the end of the last line for which less returns true
+// in the input of the given size, using binary search. The input must be
+// sorted in the sense that less returns true for lines up to a point, and
+// false for the rest. It returns the position of the terminator of the last
+// such line, so a Scanner created at the returned position returns that line
+// first (via New64(), for example). It returns 0 if less is false for all
+// lines.
+//
+// less is called with the beginning of the lines (at most ChunkSize bytes,
+// undecoded), which shares data with an internal buffer. This makes it
+// possible to find e.g. the last line with a timestamp not after a given time
+// in a log file, reading only O(log n) chunks.
+//
+// The state of the Scanner is not changed, only its input and Options are used.
+func (s *Scanner) SearchPos(size int64, less func(linePrefix []byte) bool) (pos int, err error) {
+	o := s.o
+	o.Prefetch, o.BufferPool, o.MaxLines, o.MinPos, o.PosOffset = false, nil, 0, 0, 0
+	// Lines must not be filtered or transformed, nor reported:
+	o.SkipEmpty, o.CollapseBlankLines, o.Unique, o.ValidateJSON, o.SkipInvalidJSON = false, false, false, false, false
+	o.TrimSpace, o.Transform, o.Hash, o.OnProgress, o.OnRead = false, nil, nil, nil, nil
+	if s.pf != nil {
+		s.pf.settle() // The input is read directly
+	}
+	probe := New64(s.r, size, &o)
+	if probe.o.AutoDetectLineEnding {
+		// Detect it once, at the end of the input:
+		probe.detectLineEnding()
+		probe.o.AutoDetectLineEnding = false
+	}
+	sep := probe.terminator()
+	prefix := make([]byte, o.ChunkSize)
+
+	// Search the start of the first line for which less is false:
+	lo, hi := int64(0), size
+	for lo < hi {
+		m := lo + (hi-lo)/2
+		// Start of the line containing the byte at m:
+		probe.Reset(s.r, int(m))
+		_, start, err := probe.LineBytes64()
+		if err != nil {
+			if err != io.EOF {
+				return 0, err
+			}
+			start = 0
+		}
+		line := prefix
+		if rem := size - start; int64(len(line)) > rem {
+			line = line[:rem]
+		}
+		n, _, err := readFull(s.r, line, start)
+		if err != nil && (err != io.EOF || n < len(line)) {
+			return 0, err
+		}
+		if i := probe.index(line, start, sep); i >= 0 {
+			line = line[:i]
+		}
+		if probe.dropsCR() {
+			line = line[:len(line)-o.Encoding.crLen(line)]
+		}
+		if less(line) {
+			lo = m + 1
+		} else {
+			hi = start
+		}
+	}
+
+	if lo == 0 {
+		return 0, nil
+	}
+	if lo == size {
+		// Only step back if the input ends with a terminator:
+		var end []byte
+		if size >= int64(len(sep)) {
+			end = make([]byte, len(sep))
+		}
+		if n, _, err := readFull(s.r, end, size-int64(len(end))); err != nil && (err != io.EOF || n < len(end)) {
+			return 0, err
+		}
+		if !bytes.Equal(end, sep) {
+			return int(size), nil
+		}
+	}
+	return int(lo) - len(sep), nil
+}
+
+// LastByte returns the last byte of the input of the given size (the byte at
+// size-1), reading only that byte. It returns io.EOF if size is not positive
+// (the input is empty), and ErrPosBeyondEnd if the input is shorter than size.
+// This may be used e.g. to check if the input ends with a terminator before
+// scanning it.
+func LastByte(r io.ReaderAt, size int) (byte, error) {
+	if size <= 0 {
+		return 0, io.EOF
+	}
+	if r == nil {
+		return 0, ErrNilReader
+	}
+	var b [1]byte
+	n, err := r.ReadAt(b[:], int64(size-1))
+	if n == 1 {
+		return b[0], nil
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		err = ErrPosBeyondEnd
+	}
+	return 0, err
+}
+
+// CountLines returns the number of lines in the input of the given size,
+// using the given Options (which may be nil). A terminator at the end of
+// the input does not start another line (unlike with Scanner.Line(), which
+// returns an empty line after it), so the input "a\nb" and "a\nb\n" both
+// have 2 lines, and an empty input has none.
+//
+// With a single byte Separator (the default), lines are counted by counting
+// the separators in each chunk, without scanning lines. Options filtering the
+// returned lines (SkipEmpty, CollapseBlankLines, Unique, SkipInvalidJSON,
+// MaxLines) and Transform are ignored. If SplitMode is not Lines or a custom split function is set, the tokens
+// returned by the Scanner are counted.
+func CountLines(r io.ReaderAt, size int, o *Options) (int, error) {
+	s := NewOptions(r, size, o)
+	s.o.SkipEmpty, s.o.CollapseBlankLines, s.o.Unique, s.o.MaxLines, s.o.Hash = false, false, false, 0, nil
+	s.o.ValidateJSON, s.o.SkipInvalidJSON, s.o.Transform = false, false, nil
+	defer s.Close()
+	if s.o.AutoDetectLineEnding {
+		s.detectLineEnding()
+	}
+
+	if s.split != nil || s.o.SplitMode != Lines || len(s.o.Delimiter) > 0 || s.sep != nil || s.breaks != nil {
+		return s.countLines()
+	}
+
+	count := 0
+	chunk := make([]byte, s.o.ChunkSize)
+	for end := true; !s.atMin(); end = false {
+		data := chunk
+		if rem := s.pos - int64(s.o.MinPos); int64(len(data)) > rem {
+			data = data[:rem]
+		}
+		s.pos -= int64(len(data))
+		n, err := s.readAt(data, s.pos)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if n < len(data) {
+				return 0, ErrPosBeyondEnd
+			}
+			err = nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if end && data[len(data)-1] != s.o.Separator {
+			count++ // The last line is not terminated
+		}
+		count += bytes.Count(data, []byte{s.o.Separator})
+	}
+	return count, nil
+}
+
+// countLines counts the lines by scanning them.
+func (s *Scanner) countLines() (count int, err error) {
+	var pos int64 // pos is the position of the last returned line
+	for {
+		_, linePos, err := s.lineBytes(context.Background())
+		if err != nil {
+			if err != io.EOF {
+				return 0, err
+			}
+			break
+		}
+		count, pos = count+1, linePos
+	}
+	if s.split != nil || s.o.SplitMode != Lines {
+		return count, nil // Tokens are counted as returned
+	}
+	if count > 0 && pos > int64(s.o.MinPos) {
+		count++ // The empty first line is not returned
+	}
+	if count > 0 && s.HadTrailingNewline() {
+		count-- // The empty line after the terminator is not a line
+	}
+	return count, nil
+}
@@ -0,0 +1,616 @@
+package backscanner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/icza/mighty"
+)
+
+func TestReadLastN(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	input := "Line1\nLine2\nLine3\nLine4"
+	cases := []struct {
+		n     int
+		lines []string
+		pos   int
+	}{
+		{0, nil, 0},
+		{1, []string{"Line4"}, 18},
+		{3, []string{"Line2", "Line3", "Line4"}, 6},
+		{4, []string{"Line1", "Line2", "Line3", "Line4"}, 0},
+		{10, []string{"Line1", "Line2", "Line3", "Line4"}, 0},
+	}
+
+	for _, c := range cases {
+		lines, pos, err := NewString(input, &Options{ChunkSize: 3}).ReadLastN(c.n)
+		deq(c.lines, lines)
+		eq(c.pos, pos)
+		eq(nil, err)
+	}
+
+	_, _, err := NewString(input, &Options{MaxBufferSize: 2}).ReadLastN(2)
+	eq(true, errors.Is(err, ErrLongLine))
+}
+
+func TestTailForward(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	cases := []struct {
+		input string
+		o     Options
+		n     int
+		exp   []string
+	}{
+		{"", Options{}, 2, nil},
+		{"a\nb\nc", Options{}, 2, []string{"b", "c"}},
+		{"a\nb\nc\n", Options{}, 2, []string{"b", "c"}},
+		{"a\nb\nc\n", Options{}, 0, nil},
+		{"a\nb\nc\n\n", Options{}, 2, []string{"c", ""}},
+		{"a\nb\nc\n", Options{}, 10, []string{"a", "b", "c"}},
+		{"a\n\nc\n", Options{SkipEmpty: true}, 2, []string{"a", "c"}},
+		{"a<>b<>", Options{Delimiter: []byte("<>")}, 1, []string{"b"}},
+	}
+
+	for _, c := range cases {
+		c.o.ChunkSize = 2
+		lines, err := NewString(c.input, &c.o).TailForward(c.n)
+		deq(c.exp, lines)
+		eq(nil, err)
+	}
+}
+
+func TestLines(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	input := "Line1\nLine2\nLine3\nLine4"
+	scanner := NewString(input, &Options{ChunkSize: 3})
+	cases := []struct {
+		n         int
+		lines     []string
+		positions []int
+		err       error
+	}{
+		{0, nil, nil, nil},
+		{1, []string{"Line4"}, []int{18}, nil},
+		{2, []string{"Line3", "Line2"}, []int{12, 6}, nil},
+		{3, []string{"Line1"}, []int{0}, nil},
+		{3, nil, nil, io.EOF},
+	}
+
+	for _, c := range cases {
+		lines, positions, err := scanner.Lines(c.n)
+		deq(c.lines, lines)
+		deq(c.positions, positions)
+		eq(c.err, err)
+	}
+
+	lines, _, err := NewString("LongLine1\nLine2", &Options{ChunkSize: 2, MaxBufferSize: 6}).Lines(2)
+	deq([]string{"Line2"}, lines)
+	eq(true, errors.Is(err, ErrLongLine))
+}
+
+func TestSkip(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\nLine2\nLine3\nLine4"
+	scanner := NewString(input, &Options{ChunkSize: 3})
+	skipped, err := scanner.Skip(0)
+	eq(0, skipped)
+	eq(nil, err)
+
+	skipped, err = scanner.Skip(2)
+	eq(2, skipped)
+	eq(nil, err)
+	line, pos, err := scanner.Line()
+	eq("Line2", line)
+	eq(6, pos)
+	eq(nil, err)
+
+	skipped, err = scanner.Skip(5)
+	eq(1, skipped)
+	eq(nil, err)
+
+	_, err = NewString(input, &Options{MaxBufferSize: 2}).Skip(2)
+	eq(true, errors.Is(err, ErrLongLine))
+}
+
+func TestDiscard(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\nLine2\nLine3\nLine4"
+	scanner := NewString(input, &Options{ChunkSize: 3})
+	discarded, err := scanner.Discard(0)
+	eq(0, discarded)
+	eq(nil, err)
+
+	discarded, err = scanner.Discard(2)
+	eq(2, discarded)
+	eq(nil, err)
+	line, pos, err := scanner.Line()
+	eq("Line2", line)
+	eq(6, pos)
+	eq(nil, err)
+
+	discarded, err = scanner.Discard(5)
+	eq(1, discarded)
+	eq(io.EOF, err)
+
+	discarded, err = scanner.Discard(1)
+	eq(0, discarded)
+	eq(io.EOF, err)
+}
+
+func TestLineAt(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\nLine2\nLine3\nLine4"
+	cases := []struct {
+		n      int
+		line   string
+		pos    int
+		expErr error
+	}{
+		{0, "Line4", 18, nil},
+		{1, "Line4", 18, nil},
+		{2, "Line3", 12, nil},
+		{4, "Line1", 0, nil},
+		{5, "", 0, io.EOF},
+	}
+	for _, c := range cases {
+		scanner := NewString(input, &Options{ChunkSize: 3})
+		line, pos, err := scanner.LineAt(c.n)
+		eq(c.line, line)
+		eq(c.pos, pos)
+		eq(c.expErr, err)
+	}
+
+	// Positioned before the returned line, indexing continues from there:
+	scanner := NewString(input, nil)
+	line, pos, err := scanner.LineAt(2)
+	eq("Line3", line)
+	eq(12, pos)
+	eq(nil, err)
+	eq(2, scanner.LineNumber())
+	line, pos, err = scanner.LineAt(1)
+	eq("Line2", line)
+	eq(6, pos)
+	eq(nil, err)
+	_, _, err = scanner.LineAt(2)
+	eq(io.EOF, err)
+
+	// The empty line after a trailing terminator is not counted:
+	for _, input := range []string{"a\nb\nc\n", "a\nb\nc"} {
+		scanner = NewString(input, nil)
+		line, pos, err = scanner.LineAt(1)
+		eq("c", line)
+		eq(4, pos)
+		eq(nil, err)
+		line, pos, err = scanner.LineAt(2)
+		eq("a", line)
+		eq(0, pos)
+		eq(nil, err)
+	}
+	_, _, err = NewString("\n", nil).LineAt(2)
+	eq(io.EOF, err)
+}
+
+func TestForEachLine(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	input := "Line1\nLine2\nLine3"
+	var lines []string
+	var positions []int
+	err := NewString(input, &Options{ChunkSize: 3}).ForEachLine(func(line []byte, pos int) error {
+		lines = append(lines, string(line))
+		positions = append(positions, pos)
+		return nil
+	})
+	eq(nil, err)
+	deq([]string{"Line3", "Line2", "Line1"}, lines)
+	deq([]int{12, 6, 0}, positions)
+
+	// Early stop:
+	scanner := NewString(input, nil)
+	calls := 0
+	err = scanner.ForEachLine(func(line []byte, pos int) error {
+		calls++
+		return ErrStopScan
+	})
+	eq(nil, err)
+	eq(1, calls)
+	line, _, _ := scanner.Line()
+	eq("Line2", line)
+
+	// Custom error:
+	errCustom := errors.New("custom")
+	err = NewString(input, nil).ForEachLine(func(line []byte, pos int) error {
+		return errCustom
+	})
+	eq(errCustom, err)
+
+	// Read error:
+	err = NewString(input, &Options{MaxBufferSize: 2}).ForEachLine(func(line []byte, pos int) error {
+		return nil
+	})
+	eq(true, errors.Is(err, ErrLongLine))
+}
+
+func TestFindLast(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "info 1\nerror 2\ninfo 3\nerror 4\ninfo 5"
+	scanner := NewString(input, &Options{ChunkSize: 4})
+	for _, exp := range []struct {
+		line string
+		pos  int
+	}{{"error 4", 22}, {"error 2", 7}} {
+		line, pos, err := scanner.FindLast([]byte("error"))
+		eq(exp.line, line)
+		eq(exp.pos, pos)
+		eq(nil, err)
+	}
+	_, _, err := scanner.FindLast([]byte("error"))
+	eq(io.EOF, err)
+
+	line, pos, err := NewString(input, nil).FindLastFunc(func(line []byte) bool {
+		return bytes.HasSuffix(line, []byte("3"))
+	})
+	eq("info 3", line)
+	eq(15, pos)
+	eq(nil, err)
+}
+
+func TestFindLastBefore(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "error 1\ninfo 2\nerror 3\npanic 4\nerror 5"
+	isError := func(line []byte) bool { return bytes.HasPrefix(line, []byte("error")) }
+
+	scanner := NewString(input, &Options{ChunkSize: 4})
+	_, panicPos, err := scanner.FindLast([]byte("panic"))
+	eq(nil, err)
+
+	cases := []struct {
+		pos     int
+		line    string
+		linePos int
+		err     error
+	}{
+		{panicPos, "error 3", 15, nil},
+		{len(input), "error 5", 31, nil},
+		{21, "error ", 15, nil}, // Cut at pos
+		{15, "error 1", 0, nil},
+		{7, "error 1", 0, nil},
+		{5, "error", 0, nil},
+		{0, "", 0, io.EOF},
+	}
+	for _, c := range cases {
+		line, linePos, err := scanner.FindLastBefore(c.pos, isError)
+		eq(c.line, line)
+		eq(c.linePos, linePos)
+		eq(c.err, err)
+	}
+
+	// Scanning continues after the found line:
+	scanner.FindLastBefore(panicPos, isError)
+	line, pos, err := scanner.Line()
+	eq("info 2", line)
+	eq(8, pos)
+	eq(nil, err)
+}
+
+func TestFindLastWithContext(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	input := "l1\nl2\nerror 3\nl4\nl5\nl6\nerror 7\nl8"
+	cases := []struct {
+		before, after int
+		exp           []string
+	}{
+		{0, 0, []string{"error 7"}},
+		{1, 1, []string{"l6", "error 7", "l8"}},
+		{2, 3, []string{"l5", "l6", "error 7", "l8"}},
+		{10, 0, []string{"l1", "l2", "error 3", "l4", "l5", "l6", "error 7"}},
+	}
+	for _, c := range cases {
+		scanner := NewString(input, &Options{ChunkSize: 3})
+		match, contextLines, pos, err := scanner.FindLastWithContext([]byte("error"), c.before, c.after)
+		eq("error 7", match)
+		deq(c.exp, contextLines)
+		eq(23, pos)
+		eq(nil, err)
+	}
+
+	// Continue with the lines before the context:
+	scanner := NewString(input, &Options{ChunkSize: 3})
+	scanner.FindLastWithContext([]byte("error"), 2, 0)
+	match, contextLines, pos, err := scanner.FindLastWithContext([]byte("error"), 1, 2)
+	eq("error 3", match)
+	deq([]string{"l2", "error 3", "l4"}, contextLines)
+	eq(6, pos)
+	eq(nil, err)
+
+	_, _, _, err = scanner.FindLastWithContext([]byte("error"), 1, 1)
+	eq(io.EOF, err)
+}
+
+func TestFindLastRegexp(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	input := "ERROR 12: first\nINFO 13: ok\nERROR 14: second\nINFO ERROR 15: no"
+	re := regexp.MustCompile(`^ERROR (\d+):`)
+	scanner := NewString(input, &Options{ChunkSize: 5})
+	line, pos, submatches, err := scanner.FindLastRegexp(re)
+	eq("ERROR 14: second", line)
+	eq(28, pos)
+	deq([]string{"ERROR 14:", "14"}, submatches)
+	eq(nil, err)
+
+	line, pos, submatches, err = scanner.FindLastRegexp(re)
+	eq("ERROR 12: first", line)
+	eq(0, pos)
+	deq([]string{"ERROR 12:", "12"}, submatches)
+	eq(nil, err)
+
+	_, _, submatches, err = scanner.FindLastRegexp(re)
+	eq(0, len(submatches))
+	eq(io.EOF, err)
+
+	_, _, _, err = NewString(input, &Options{MaxBufferSize: 10}).FindLastRegexp(re)
+	eq(true, errors.Is(err, ErrLongLine))
+}
+
+func TestWriteTo(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	var _ io.WriterTo = (*Scanner)(nil)
+
+	input := "Line1\r\nLine2\nLine3"
+	buf := &bytes.Buffer{}
+	n, err := NewString(input, &Options{ChunkSize: 3}).WriteTo(buf)
+	eq("Line3\nLine2\nLine1\n", buf.String())
+	eq(int64(buf.Len()), n)
+	eq(nil, err)
+
+	buf.Reset()
+	n, err = NewString(input, &Options{KeepTerminator: true}).WriteTo(buf)
+	eq("Line3Line2\nLine1\r\n", buf.String())
+	eq(int64(len(input)), n)
+	eq(nil, err)
+
+	sb := &strings.Builder{}
+	n, err = NewString("long line\nok", &Options{ChunkSize: 3, MaxBufferSize: 5}).WriteTo(sb)
+	eq("ok\n", sb.String())
+	eq(int64(3), n)
+	eq(true, errors.Is(err, ErrLongLine))
+}
+
+func TestForwardFrom(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	input := "Line1\nanchor\nLine3\nLine4\n"
+	scanner := NewString(input, &Options{ChunkSize: 4})
+	_, pos, err := scanner.FindLast([]byte("anchor"))
+	eq(nil, err)
+
+	forward := func(pos int) (lines []string) {
+		fs, err := scanner.ForwardFrom(pos)
+		eq(nil, err)
+		for fs.Scan() {
+			lines = append(lines, fs.Text())
+		}
+		eq(nil, fs.Err())
+		return
+	}
+
+	deq([]string{"anchor", "Line3", "Line4"}, forward(pos))
+	deq([]string{"Line1", "anchor", "Line3", "Line4"}, forward(0))
+	deq([]string(nil), forward(len(input)))
+	deq([]string(nil), forward(len(input)+10))
+
+	// The Scanner is not affected:
+	line, _, err := scanner.Line()
+	eq("Line1", line)
+	eq(nil, err)
+
+	// Only the scanned part of the input is read, positions include PosOffset:
+	scanner = NewOptions(strings.NewReader(input), 19, &Options{PosOffset: 100})
+	deq([]string{"anchor", "Line3"}, forward(106))
+
+	// Positions before the start of the input:
+	for _, pos := range []int{99, -1} {
+		fs, err := scanner.ForwardFrom(pos)
+		eq(true, fs == nil)
+		eq(fmt.Sprintf("invalid position: %d (before the start of the input)", pos), err.Error())
+	}
+}
+
+func TestSearchPos(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	var lines []string
+	for i := 10; i < 100; i += 2 {
+		lines = append(lines, fmt.Sprint(i, " some message", strings.Repeat("!", i%7)))
+	}
+
+	for _, crlf := range []bool{false, true} {
+		sep := "\n"
+		if crlf {
+			sep = "\r\n"
+		}
+		input := strings.Join(lines, sep) + sep
+		r := &countingReaderAt{r: strings.NewReader(input)}
+		scanner := NewOptions(r, len(input), &Options{ChunkSize: 8})
+		if crlf {
+			scanner = NewOptions(r, len(input), &Options{ChunkSize: 8, Delimiter: []byte(sep)})
+		}
+
+		for _, target := range []string{"05", "10", "11", "12", "50", "51", "98", "99"} {
+			r.calls = 0
+			pos, err := scanner.SearchPos(int64(len(input)), func(linePrefix []byte) bool {
+				return string(linePrefix[:2]) <= target
+			})
+			eq(nil, err)
+			eq(true, r.calls < 100)
+
+			var exp string
+			for _, line := range lines {
+				if line[:2] <= target {
+					exp = line
+				}
+			}
+			line, _, err := New(strings.NewReader(input), pos).Line()
+			if exp == "" {
+				eq(0, pos)
+				eq(io.EOF, err)
+			} else {
+				eq(exp, line)
+				eq(nil, err)
+			}
+		}
+	}
+
+	// All lines:
+	input := "a\nb\nc"
+	pos, err := New(strings.NewReader(input), 0).SearchPos(int64(len(input)), func([]byte) bool { return true })
+	eq(len(input), pos)
+	eq(nil, err)
+
+	// UTF-16: terminators are encoded, '\u0a00' has a '\n' byte:
+	for _, e := range []Encoding{UTF16LE, UTF16BE} {
+		enc := e.encode("1\u0100\u0a00\r\n2\u0100\u0a00\r\n3\u0100\u0a00\r\n4\u0100\u0a00\r\n")
+		o := &Options{Encoding: e, ChunkSize: 8}
+		pos, err := NewBytes(enc, o).SearchPos(int64(len(enc)), func(linePrefix []byte) bool {
+			eq(len(e.encode("1\u0100\u0a00")), len(linePrefix))
+			return bytes.Compare(linePrefix, e.encode("2\u0100\u0a00")) <= 0
+		})
+		eq(nil, err)
+		eq(18, pos)
+		line, _, err := New64(bytes.NewReader(enc), int64(pos), o).Line()
+		eq("2\u0100\u0a00", line)
+		eq(nil, err)
+	}
+
+	// Filtering and transforming options are ignored:
+	for _, o := range []Options{{ChunkSize: 3, SkipEmpty: true}, {CollapseBlankLines: true}} {
+		input = "1\n2"
+		pos, err = NewString(input, &o).SearchPos(int64(len(input)), func(linePrefix []byte) bool {
+			return string(linePrefix) <= "1"
+		})
+		eq(1, pos)
+		eq(nil, err)
+	}
+	input = "1\n\n2\n2\n3\n4"
+	for _, o := range []Options{
+		{SkipEmpty: true}, {Unique: true}, {CollapseBlankLines: true},
+		{ValidateJSON: true, SkipInvalidJSON: true},
+		{Transform: func(line []byte) []byte { return []byte("9") }},
+	} {
+		pos, err = NewString(input, &o).SearchPos(int64(len(input)), func(linePrefix []byte) bool {
+			return string(linePrefix) <= "2"
+		})
+		eq(6, pos)
+		eq(nil, err)
+	}
+
+	// Detected line ending:
+	input = "1\r2\r3\r4\r5\r"
+	pos, err = NewString(input, &Options{AutoDetectLineEnding: true}).SearchPos(int64(len(input)), func(linePrefix []byte) bool {
+		return string(linePrefix) <= "3"
+	})
+	eq(5, pos)
+	eq(nil, err)
+}
+
+func TestLastByte(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	cases := []struct {
+		input string
+		size  int
+		b     byte
+		err   error
+	}{
+		{"", 0, 0, io.EOF},
+		{"abc", 0, 0, io.EOF},
+		{"abc", -1, 0, io.EOF},
+		{"abc", 3, 'c', nil},
+		{"abc\n", 4, '\n', nil},
+		{"abc", 1, 'a', nil},
+		{"abc", 4, 0, ErrPosBeyondEnd},
+	}
+
+	for _, c := range cases {
+		b, err := LastByte(strings.NewReader(c.input), c.size)
+		eq(c.b, b)
+		eq(c.err, err)
+	}
+
+	_, err := LastByte(nil, 1)
+	eq(ErrNilReader, err)
+	_, err = LastByte(&flakyReaderAt{r: strings.NewReader("abc"), n: 1}, 3)
+	eq(errFlaky, err)
+}
+
+func TestCountLines(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	cases := []struct {
+		input string
+		o     Options
+		exp   int
+	}{
+		{"", Options{}, 0},
+		{"\n", Options{}, 1},
+		{"a", Options{}, 1},
+		{"a\nb", Options{}, 2},
+		{"a\nb\n", Options{}, 2},
+		{"\na\n\nb\n\n", Options{}, 5},
+		{"a\nb\nc", Options{MinPos: 2}, 2},
+		{"a\nb\nc", Options{SkipEmpty: true, MaxLines: 1}, 3},
+		{"a<>b<>", Options{Delimiter: []byte("<>")}, 2},
+		{"<>a<>b", Options{Delimiter: []byte("<>")}, 3},
+		{"a\r\n\r\nb", Options{Delimiter: []byte("\r\n")}, 3},
+		{"", Options{Delimiter: []byte("<>")}, 0},
+		{"a\x00\n\x00b\x00\n\x00", Options{Encoding: UTF16LE}, 2},
+		{"a\rb\rc\r", Options{AutoDetectLineEnding: true}, 3},
+		{"a\r\nb\r\n", Options{AutoDetectLineEnding: true}, 2},
+		{"a\na\nb\nb\n", Options{ValidateJSON: true, SkipInvalidJSON: true, UnicodeLineBreaks: true}, 4},
+		{"a\n\n", Options{Transform: func([]byte) []byte { return nil }, SkipEmpty: true, Delimiter: []byte("\n")}, 2},
+		{"a\na\nb\nb\n", Options{Unique: true}, 4},
+		{"a\na\nb\nb\n", Options{Unique: true, Delimiter: []byte("\n")}, 4},
+		{"  a b", Options{SplitMode: Words}, 2},
+		{"a b\n", Options{SplitMode: Words}, 2},
+		{"p1\n\np2\n\n", Options{SplitMode: Paragraphs}, 2},
+		{"\np1\n\np2", Options{SplitMode: Paragraphs}, 2},
+	}
+
+	for _, c := range cases {
+		for _, chunkSize := range []int{1, 2, 3, 100} {
+			c.o.ChunkSize = chunkSize
+			count, err := CountLines(strings.NewReader(c.input), len(c.input), &c.o)
+			eq(nil, err)
+			eq(c.exp, count)
+		}
+	}
+
+	_, err := CountLines(strings.NewReader("a\nb"), 4, nil)
+	eq(ErrPosBeyondEnd, err)
+}
+
+func BenchmarkCountLines(b *testing.B) {
+	input := strings.Repeat("a short line\n", 100<<10)
+	r := strings.NewReader(input)
+	for i := 0; i < b.N; i++ {
+		if _, err := CountLines(r, len(input), nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
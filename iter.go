@@ -0,0 +1,42 @@
+//go:build go1.23
+// +build go1.23
+
+package backscanner
+
+import "iter"
+
+// All returns an iterator over the remaining lines of the input and their
+// absolute byte-positions, in the same order as returned by Line().
+//
+// Iteration stops at the end of the input or at the first error.
+// Errors other than io.EOF can be retrieved afterward with Err().
+func (s *Scanner) All() iter.Seq2[string, int] {
+	return func(yield func(string, int) bool) {
+		for {
+			line, pos, err := s.Line()
+			if err != nil || !yield(line, pos) {
+				return
+			}
+		}
+	}
+}
+
+// AllBytes returns an iterator over the remaining lines of the input and their
+// absolute byte-positions, in the same order as returned by LineBytes().
+//
+// The yielded line slices share data with the internal buffer of the Scanner
+// just like the ones returned by LineBytes(), and are only valid until the
+// next iteration.
+//
+// Iteration stops at the end of the input or at the first error.
+// Errors other than io.EOF can be retrieved afterward with Err().
+func (s *Scanner) AllBytes() iter.Seq2[[]byte, int] {
+	return func(yield func([]byte, int) bool) {
+		for {
+			line, pos, err := s.LineBytes()
+			if err != nil || !yield(line, pos) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+//go:build go1.23
+// +build go1.23
+
+package backscanner
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/icza/mighty"
+)
+
+func TestAll(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	input := "Line1\nLine2\nLine3"
+	var lines []string
+	var poss []int
+	scanner := New(strings.NewReader(input), len(input))
+	for line, pos := range scanner.All() {
+		lines = append(lines, line)
+		poss = append(poss, pos)
+	}
+	deq([]string{"Line3", "Line2", "Line1"}, lines)
+	deq([]int{12, 6, 0}, poss)
+	eq(nil, scanner.Err())
+
+	// Early break, then continue with the rest:
+	scanner = NewOptions(strings.NewReader(input), len(input), &Options{ChunkSize: 2})
+	for line := range scanner.AllBytes() {
+		eq("Line3", string(line))
+		break
+	}
+	line, pos, err := scanner.Line()
+	eq("Line2", line)
+	eq(6, pos)
+	eq(nil, err)
+}
+
+type errReaderAt struct {
+	err error
+}
+
+func (r errReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	return 0, r.err
+}
+
+func TestAllErr(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	myErr := errors.New("my error")
+	scanner := New(errReaderAt{myErr}, 10)
+	count := 0
+	for range scanner.All() {
+		count++
+	}
+	eq(0, count)
+	eq(true, errors.Is(scanner.Err(), myErr))
+}
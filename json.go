@@ -0,0 +1,17 @@
+package backscanner
+
+import "encoding/json"
+
+// LineJSON is like LineBytes(), but it also tells if the returned line is
+// valid JSON (as reported by json.Valid()). If ValidateJSON is set, the line
+// is already validated while scanning (and skipped if invalid and
+// SkipInvalidJSON is set), else it's validated by LineJSON.
+func (s *Scanner) LineJSON() (line []byte, pos int, valid bool, err error) {
+	if line, pos, err = s.LineBytes(); err != nil {
+		return nil, 0, false, err
+	}
+	if s.o.ValidateJSON {
+		return line, pos, s.valid, nil
+	}
+	return line, pos, json.Valid(line), nil
+}
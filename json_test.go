@@ -0,0 +1,46 @@
+package backscanner
+
+import (
+	"io"
+	"testing"
+
+	"github.com/icza/mighty"
+)
+
+func TestLineJSON(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line  string
+		pos   int
+		valid bool
+	}
+
+	input := `{"id":1}` + "\n" + `[1, 2]` + "\n\n" + `"x"` + "\n" + `{"id":2,"msg":"tru`
+	cases := []struct {
+		o    Options
+		exps []result
+	}{
+		{Options{}, []result{{`{"id":2,"msg":"tru`, 21, false}, {`"x"`, 17, true}, {"", 16, false}, {"[1, 2]", 9, true}, {`{"id":1}`, 0, true}}},
+		{Options{ValidateJSON: true}, []result{{`{"id":2,"msg":"tru`, 21, false}, {`"x"`, 17, true}, {"", 16, false}, {"[1, 2]", 9, true}, {`{"id":1}`, 0, true}}},
+		{Options{ValidateJSON: true, SkipInvalidJSON: true}, []result{{`"x"`, 17, true}, {"[1, 2]", 9, true}, {`{"id":1}`, 0, true}}},
+		{Options{SkipInvalidJSON: true}, []result{{`{"id":2,"msg":"tru`, 21, false}, {`"x"`, 17, true}, {"", 16, false}, {"[1, 2]", 9, true}, {`{"id":1}`, 0, true}}},
+	}
+
+	for _, c := range cases {
+		for chunkSize := 1; chunkSize <= 8; chunkSize++ {
+			o := c.o
+			o.ChunkSize = chunkSize
+			scanner := NewString(input, &o)
+			for i, exp := range c.exps {
+				line, pos, valid, err := scanner.LineJSON()
+				eq(exp, result{string(line), pos, valid})
+				eq(nil, err)
+				eq(i+1, scanner.LineNumber())
+			}
+			_, _, valid, err := scanner.LineJSON()
+			eq(false, valid)
+			eq(io.EOF, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package backscanner
+
+import (
+	"errors"
+	"io"
+)
+
+// errReader is an io.Reader that always returns an error.
+type errReader struct {
+	err error
+}
+
+// Read implements io.Reader.
+func (er errReader) Read(p []byte) (int, error) {
+	return 0, er.err
+}
+
+// LineReader returns a reader of the line that LineBytes() (or Line()) failed
+// to return with ErrLongLine, so its content can be processed without holding
+// it in memory. The reader streams the bytes of the line in forward order,
+// read directly from the input (as long as it's valid): the line is not
+// decoded (Encoding or Decoder is not applied), its terminator is not included,
+// but a terminal '\r' is kept.
+//
+// To find the start of the line, the input before the buffered data is
+// searched backward for the preceding terminator, reading ChunkSize bytes at
+// a time. Scanning may then be continued with the line before the long line,
+// which is counted as a returned line. If reading the input fails, the
+// returned reader reports the error, which is also reported by subsequent
+// calls to LineBytes().
+//
+// LineReader returns nil if the last error was not ErrLongLine, or if lines
+// are not split by terminators (SplitMode is not Lines or a custom split
+// function is used).
+func (s *Scanner) LineReader() io.Reader {
+	if !errors.Is(s.err, ErrLongLine) || s.split != nil || s.o.SplitMode != Lines {
+		return nil
+	}
+	end := s.pos + int64(len(s.buf)-s.tail)
+
+	// The terminator may be in the buffered data (the line exceeds MaxLineSize):
+	var sep []byte
+	i, sepLen := s.lastSep(s.buf[:len(s.buf)-s.tail])
+	sepPos := s.pos + int64(i)
+	if i >= 0 {
+		sep = s.buf[i : i+sepLen]
+	} else {
+		var err error
+		if sepPos, sep, err = s.findSep(); err != nil {
+			s.err = err
+			return errReader{err}
+		}
+		sepLen = len(sep)
+	}
+	s.term, s.nterm = s.nterm, append(s.term[:0], sep...)
+	s.cr = s.cr[:0]
+
+	// Continue with the line before the long line:
+	s.buf = s.arr[len(s.arr):]
+	s.clean, s.tail, s.chunk = 0, 0, 0
+	s.canUnread = false
+	s.lines++
+	s.end = end
+	if sepPos < 0 {
+		s.pos, s.err = int64(s.o.MinPos), io.EOF
+		s.lpos = s.pos
+		return io.NewSectionReader(s.r, s.pos, end-s.pos)
+	}
+	start := sepPos + int64(sepLen)
+	s.lpos = start
+	s.err = nil
+	if s.o.KeepTerminator {
+		// The kept terminator is needed in buf:
+		s.pos = start
+		for len(s.buf) < sepLen && s.err == nil {
+			s.readMore()
+		}
+		if s.err == nil {
+			s.tail = sepLen
+		}
+	} else {
+		s.pos = sepPos
+	}
+	return io.NewSectionReader(s.r, start, end-start)
+}
+
+// findSep searches the input before the buffered data backward for the last
+// terminator, and returns its position and bytes. pos is -1 if there is no
+// terminator before the buffered data (up to MinPos).
+func (s *Scanner) findSep() (pos int64, sep []byte, err error) {
+	// Bytes of a terminator straddling the boundary may be in buf:
+	overlap := s.o.DelimiterLookback
+	if max := len(s.buf) - s.tail; overlap > max {
+		overlap = max
+	}
+	min := int64(s.o.MinPos)
+	chunk := make([]byte, s.o.ChunkSize+s.o.DelimiterLookback)
+	for hi := s.pos + int64(overlap); hi > min; {
+		lo := hi - int64(len(chunk))
+		if lo < min {
+			lo = min
+		}
+		data := chunk[:hi-lo]
+		n, err := s.readAt(data, lo)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if n < len(data) {
+				return -1, nil, ErrPosBeyondEnd
+			}
+			err = nil
+		}
+		if err != nil {
+			return -1, nil, err
+		}
+		if i, size := s.lastSepAt(data, lo); i >= 0 {
+			return lo + int64(i), data[i : i+size], nil
+		}
+		if lo == min {
+			break
+		}
+		// Continue before data, overlapping it for terminators straddling the boundary:
+		hi = lo + int64(s.o.DelimiterLookback)
+	}
+	return -1, nil, nil
+}
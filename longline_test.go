@@ -0,0 +1,78 @@
+package backscanner
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/icza/mighty"
+)
+
+func TestLineReader(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	long := strings.Repeat("0123456789", 10)
+
+	cases := []struct {
+		input string
+		o     Options
+		exps  []string // Lines, "*" marks the long line
+	}{
+		{"first\n" + long + "\nlast", Options{MaxBufferSize: 16}, []string{"last", "*", "first"}},
+		{long + "\nlast", Options{MaxBufferSize: 16}, []string{"last", "*"}},
+		{long, Options{MaxBufferSize: 16}, []string{"*"}},
+		{"a\n" + long + "\nb", Options{MaxLineSize: 16}, []string{"b", "*", "a"}},
+		{"a\r\n" + long + "\r\nb\r\n", Options{MaxBufferSize: 16}, []string{"", "b", "*\r", "a"}},
+		{"a<>" + long + "<>b", Options{MaxBufferSize: 16, Delimiter: []byte("<>")}, []string{"b", "*", "a"}},
+		{"a<>" + long + "<>b", Options{MaxBufferSize: 16, Delimiter: []byte("<>"), DelimiterLookback: 5}, []string{"b", "*", "a"}},
+		{"a\n" + long + "\nb", Options{MaxBufferSize: 16, KeepTerminator: true}, []string{"b", "*", "a\n"}},
+		{"a\n" + long + "\nb", Options{MaxBufferSize: 16, MinPos: 2}, []string{"b", "*"}},
+	}
+
+	for _, c := range cases {
+		for chunkSize := 1; chunkSize <= 8; chunkSize++ {
+			o := c.o
+			o.ChunkSize = chunkSize
+			scanner := NewString(c.input, &o)
+			eq(nil, scanner.LineReader())
+			var lines []string
+			for {
+				line, _, err := scanner.Line()
+				if errors.Is(err, ErrLongLine) {
+					r := scanner.LineReader()
+					eq(true, r != nil)
+					data, err := ioutil.ReadAll(r)
+					eq(nil, err)
+					lines = append(lines, strings.Replace(string(data), long, "*", 1))
+					continue
+				}
+				if err != nil {
+					eq(io.EOF, err)
+					break
+				}
+				lines = append(lines, line)
+			}
+			deq(c.exps, lines)
+			eq(len(c.exps), scanner.LineNumber())
+		}
+	}
+
+	// Read error while searching the start of the line:
+	input := "a\n" + long
+	scanner := NewOptions(strings.NewReader(input), len(input)+1, &Options{ChunkSize: 4, MaxBufferSize: 16})
+	_, _, err := scanner.Line()
+	eq(ErrPosBeyondEnd, err)
+	eq(nil, scanner.LineReader())
+
+	// The 5th read is the first one searching for the start of the line:
+	r := &flakyReaderAt{r: strings.NewReader(input), n: 5}
+	scanner = NewOptions(r, len(input), &Options{ChunkSize: 4, MaxBufferSize: 16})
+	_, _, err = scanner.Line()
+	eq(true, errors.Is(err, ErrLongLine))
+	_, err = ioutil.ReadAll(scanner.LineReader())
+	eq(true, errors.Is(err, errFlaky))
+	_, _, err = scanner.Line()
+	eq(true, errors.Is(err, errFlaky))
+}
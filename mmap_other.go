@@ -0,0 +1,15 @@
+//go:build !unix
+// +build !unix
+
+package backscanner
+
+// NewMmap memory-maps the named file and returns a new Scanner positioned at
+// its end, with the given Options (which may be nil).
+// The file is unmapped when Scanner.Close() is called.
+//
+// On platforms where memory-mapping is not supported (and before Go 1.19,
+// which added the unix build constraint), the file is read using regular
+// reads, just like with NewFromFile().
+func NewMmap(name string, o *Options) (*Scanner, error) {
+	return NewFromFile(name, o)
+}
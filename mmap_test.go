@@ -0,0 +1,43 @@
+package backscanner
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/icza/mighty"
+)
+
+func TestNewMmap(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "test.log")
+	eq(nil, os.WriteFile(name, []byte("Line1\nLine2\nLine3"), 0644))
+
+	scanner, err := NewMmap(name, &Options{ChunkSize: 4})
+	eq(nil, err)
+	for _, exp := range []string{"Line3", "Line2", "Line1"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	_, _, err = scanner.Line()
+	eq(io.EOF, err)
+	eq(nil, scanner.Close())
+	eq(nil, scanner.Close())
+
+	// Empty file:
+	empty := filepath.Join(dir, "empty.log")
+	eq(nil, os.WriteFile(empty, nil, 0644))
+	scanner, err = NewMmap(empty, nil)
+	eq(nil, err)
+	_, _, err = scanner.Line()
+	eq(io.EOF, err)
+	eq(nil, scanner.Close())
+
+	_, err = NewMmap(filepath.Join(dir, "missing.log"), nil)
+	eq(true, errors.Is(err, os.ErrNotExist))
+}
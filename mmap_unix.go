@@ -0,0 +1,89 @@
+//go:build unix
+// +build unix
+
+package backscanner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// NewMmap memory-maps the named file and returns a new Scanner positioned at
+// its end, with the given Options (which may be nil).
+// The file is unmapped when Scanner.Close() is called.
+//
+// On platforms where memory-mapping is not supported (and before Go 1.19,
+// which added the unix build constraint), the file is read using regular
+// reads, just like with NewFromFile().
+func NewMmap(name string, o *Options) (*Scanner, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close() // The mapping remains valid after closing the file.
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	m := &mmapReaderAt{}
+	if size := fi.Size(); size > 0 {
+		if int64(int(size)) != size {
+			return nil, fmt.Errorf("file too large to map: %d", size)
+		}
+		if m.data, err = syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED); err != nil {
+			return nil, fmt.Errorf("failed to map file: %w", err)
+		}
+	}
+	return NewOptions(m, len(m.data), o), nil
+}
+
+// errUnmapped is returned when reading an unmapped mmapReaderAt.
+var errUnmapped = errors.New("file is unmapped")
+
+// mmapReaderAt is an io.ReaderAt over a memory-mapped file.
+type mmapReaderAt struct {
+	data   []byte // data is the mapped content
+	closed bool   // closed tells if the file is unmapped
+}
+
+// ReadAt implements io.ReaderAt.
+func (m *mmapReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if m.closed {
+		return 0, errUnmapped
+	}
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n = copy(p, m.data[off:])
+	if n < len(p) {
+		err = io.EOF
+	}
+	return
+}
+
+// Size returns the size of the mapped file.
+func (m *mmapReaderAt) Size() int64 {
+	return int64(len(m.data))
+}
+
+// Close unmaps the file.
+func (m *mmapReaderAt) Close() error {
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	if m.data == nil {
+		return nil
+	}
+	data := m.data
+	m.data = nil
+	return syscall.Munmap(data)
+}
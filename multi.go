@@ -0,0 +1,124 @@
+package backscanner
+
+import (
+	"errors"
+	"io"
+	"sort"
+)
+
+// NewMulti returns a new Scanner that scans multiple inputs (parts) as if they
+// were concatenated, starting at the end of the last part, with the given
+// Options (which may be nil). sizes holds the sizes of the parts.
+//
+// Lines do not span parts: the first line of a part and the last line of the
+// previous part are returned as separate lines (even if the previous part
+// does not end with a terminator). Returned positions are global: positions
+// in the concatenation of the parts.
+//
+// The MinPos option is not used. Scanner.Close() closes all parts that
+// implement io.Closer.
+func NewMulti(parts []io.ReaderAt, sizes []int, o *Options) *Scanner {
+	m := &multiReaderAt{parts: parts}
+	for _, size := range sizes {
+		m.starts = append(m.starts, m.size)
+		m.size += int64(size)
+	}
+
+	var so Options
+	if o != nil {
+		so = *o
+	}
+	so.MinPos = 0
+	s := New64(m, m.size, &so)
+	if len(m.starts) > 0 {
+		s.starts, s.part = m.starts, len(m.starts)-1
+		s.o.MinPos = int(m.starts[s.part])
+	}
+	return s
+}
+
+// restoreParts restores the parts (their start positions) of NewMulti()
+// after Reset() to continue scanning at the current position.
+func (s *Scanner) restoreParts(starts []int64) {
+	if starts == nil {
+		return
+	}
+	s.starts = starts
+	// The part containing the byte before pos:
+	s.part = sort.Search(len(starts), func(i int) bool { return starts[i] >= s.pos }) - 1
+	if s.part < 0 {
+		s.part = 0
+	}
+	s.o.MinPos = int(starts[s.part])
+	if s.err == io.EOF && !s.atMin() {
+		s.err = nil
+	}
+}
+
+// nextPart moves on to the previous part of NewMulti() if the start of the
+// current part is reached, and tells if it did.
+func (s *Scanner) nextPart() bool {
+	if s.part == 0 || !s.atMin() {
+		return false
+	}
+	s.part--
+	s.o.MinPos = int(s.starts[s.part])
+	s.err = nil
+	// Data before the start of the part is not part of any line:
+	s.buf, s.tail, s.clean = s.buf[:0], 0, 0
+	return true
+}
+
+// errNegativeOffset is returned by ReadAt() for a negative offset.
+var errNegativeOffset = errors.New("negative offset")
+
+// multiReaderAt is the concatenation of multiple io.ReaderAts.
+type multiReaderAt struct {
+	parts  []io.ReaderAt
+	starts []int64 // starts holds the start positions of the parts
+	size   int64   // size is the total size of the parts
+}
+
+// ReadAt implements io.ReaderAt.
+func (m *multiReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+	// Index of the part containing off:
+	i := sort.Search(len(m.starts), func(i int) bool { return m.starts[i] > off }) - 1
+	for ; i >= 0 && i < len(m.parts) && len(p) > 0; i++ {
+		end := m.size
+		if i+1 < len(m.starts) {
+			end = m.starts[i+1]
+		}
+		q := p
+		if int64(len(q)) > end-off {
+			q = q[:end-off]
+		}
+		var k int
+		k, err = m.parts[i].ReadAt(q, off-m.starts[i])
+		n, p, off = n+k, p[k:], off+int64(k)
+		if err == io.EOF && k == len(q) && len(p) > 0 {
+			err = nil
+		}
+		if err != nil {
+			return
+		}
+	}
+	if len(p) > 0 {
+		err = io.EOF
+	}
+	return
+}
+
+// Close closes all parts that implement io.Closer, and returns the first error.
+func (m *multiReaderAt) Close() (err error) {
+	for _, r := range m.parts {
+		if c, ok := r.(io.Closer); ok {
+			if err2 := c.Close(); err == nil {
+				err = err2
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package backscanner
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/icza/mighty"
+)
+
+// closeRecorder records if it is closed.
+type closeRecorder struct {
+	io.ReaderAt
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestNewMulti(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line string
+		pos  int
+	}
+
+	inputs := []string{"a1\na2", "", "b1\nb2\n", "\nc1", "d1"}
+	exps := []result{{"d1", 14}, {"c1", 12}, {"", 11}, {"b2", 8}, {"b1", 5}, {"a2", 3}, {"a1", 0}}
+
+	for _, chunkSize := range []int{1, 2, 100} {
+		for _, mode := range []SplitMode{Lines, Words} {
+			var parts []io.ReaderAt
+			var sizes []int
+			for _, in := range inputs {
+				parts = append(parts, &closeRecorder{ReaderAt: strings.NewReader(in)})
+				sizes = append(sizes, len(in))
+			}
+			scanner := NewMulti(parts, sizes, &Options{ChunkSize: chunkSize, SplitMode: mode})
+			for _, exp := range exps {
+				if mode == Words && exp.line == "" {
+					continue
+				}
+				line, pos, err := scanner.Line()
+				eq(exp, result{line, pos})
+				eq(nil, err)
+			}
+			_, _, err := scanner.Line()
+			eq(io.EOF, err)
+
+			eq(nil, scanner.Close())
+			for _, p := range parts {
+				eq(true, p.(*closeRecorder).closed)
+			}
+		}
+	}
+
+	// Restoring a state:
+	parts := []io.ReaderAt{strings.NewReader("a\nb"), strings.NewReader("c\nd")}
+	scanner := NewMulti(parts, []int{3, 3}, nil)
+	for _, exp := range []string{"d", "c"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	st := scanner.State()
+	scanner = NewMulti(parts, []int{3, 3}, nil)
+	scanner.RestoreState(st)
+	for _, exp := range []string{"b", "a"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	_, _, err := scanner.Line()
+	eq(io.EOF, err)
+
+	// No parts:
+	_, _, err = NewMulti(nil, nil, nil).Line()
+	eq(io.EOF, err)
+}
@@ -0,0 +1,212 @@
+package backscanner
+
+import (
+	"hash"
+	"io"
+	"time"
+)
+
+// Option is a functional option that sets a field of Options,
+// to be used with NewFunc().
+type Option func(o *Options)
+
+// NewFunc returns a new Scanner with the given functional options applied.
+// Option values not set (and invalid values) are replaced with their default
+// values, just like with NewOptions().
+func NewFunc(r io.ReaderAt, pos int, opts ...Option) *Scanner {
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return NewOptions(r, pos, &o)
+}
+
+// WithChunkSize sets the ChunkSize option.
+func WithChunkSize(n int) Option {
+	return func(o *Options) { o.ChunkSize = n }
+}
+
+// WithMaxBufferSize sets the MaxBufferSize option.
+func WithMaxBufferSize(n int) Option {
+	return func(o *Options) { o.MaxBufferSize = n }
+}
+
+// WithSeparator sets the Separator option.
+func WithSeparator(b byte) Option {
+	return func(o *Options) { o.Separator = b }
+}
+
+// WithDelimiter sets the Delimiter option.
+func WithDelimiter(delim []byte) Option {
+	return func(o *Options) { o.Delimiter = delim }
+}
+
+// WithKeepTerminator sets the KeepTerminator option.
+func WithKeepTerminator(keep bool) Option {
+	return func(o *Options) { o.KeepTerminator = keep }
+}
+
+// WithKeepCR sets the KeepCR option.
+func WithKeepCR(keep bool) Option {
+	return func(o *Options) { o.KeepCR = keep }
+}
+
+// WithEncoding sets the Encoding option.
+func WithEncoding(e Encoding) Option {
+	return func(o *Options) { o.Encoding = e }
+}
+
+// WithSplitMode sets the SplitMode option.
+func WithSplitMode(m SplitMode) Option {
+	return func(o *Options) { o.SplitMode = m }
+}
+
+// WithBufferPool sets the BufferPool option.
+func WithBufferPool(p BufferPool) Option {
+	return func(o *Options) { o.BufferPool = p }
+}
+
+// WithMaxLines sets the MaxLines option.
+func WithMaxLines(n int) Option {
+	return func(o *Options) { o.MaxLines = n }
+}
+
+// WithMinPos sets the MinPos option.
+func WithMinPos(pos int) Option {
+	return func(o *Options) { o.MinPos = pos }
+}
+
+// WithSkipEmpty sets the SkipEmpty option.
+func WithSkipEmpty(skip bool) Option {
+	return func(o *Options) { o.SkipEmpty = skip }
+}
+
+// WithTrimSpace sets the TrimSpace option.
+func WithTrimSpace(trim bool) Option {
+	return func(o *Options) { o.TrimSpace = trim }
+}
+
+// WithUnicodeLineBreaks sets the UnicodeLineBreaks option.
+func WithUnicodeLineBreaks(recognize bool) Option {
+	return func(o *Options) { o.UnicodeLineBreaks = recognize }
+}
+
+// WithDecoder sets the Decoder option.
+func WithDecoder(t Transformer) Option {
+	return func(o *Options) { o.Decoder = t }
+}
+
+// WithStripBOM sets the StripBOM option.
+func WithStripBOM(strip bool) Option {
+	return func(o *Options) { o.StripBOM = strip }
+}
+
+// WithValidatePos sets the ValidatePos option.
+func WithValidatePos(validate bool) Option {
+	return func(o *Options) { o.ValidatePos = validate }
+}
+
+// WithAdaptiveChunk sets the AdaptiveChunk option.
+func WithAdaptiveChunk(adaptive bool) Option {
+	return func(o *Options) { o.AdaptiveChunk = adaptive }
+}
+
+// WithGrowthChunks sets the GrowthChunks option.
+func WithGrowthChunks(n int) Option {
+	return func(o *Options) { o.GrowthChunks = n }
+}
+
+// WithPrefetch sets the Prefetch option.
+func WithPrefetch(prefetch bool) Option {
+	return func(o *Options) { o.Prefetch = prefetch }
+}
+
+// WithReadRetry sets the ReadRetry option.
+func WithReadRetry(retry func(err error, attempt int) bool) Option {
+	return func(o *Options) { o.ReadRetry = retry }
+}
+
+// WithReadTimeout sets the ReadTimeout option.
+func WithReadTimeout(d time.Duration) Option {
+	return func(o *Options) { o.ReadTimeout = d }
+}
+
+// WithOnProgress sets the OnProgress option.
+func WithOnProgress(fn func(pos int)) Option {
+	return func(o *Options) { o.OnProgress = fn }
+}
+
+// WithOnRead sets the OnRead option.
+func WithOnRead(fn func(offset int64, n int, dur time.Duration, err error)) Option {
+	return func(o *Options) { o.OnRead = fn }
+}
+
+// WithShrinkBuffer sets the ShrinkBuffer option.
+func WithShrinkBuffer(shrink bool) Option {
+	return func(o *Options) { o.ShrinkBuffer = shrink }
+}
+
+// WithInitialBufferSize sets the InitialBufferSize option.
+func WithInitialBufferSize(n int) Option {
+	return func(o *Options) { o.InitialBufferSize = n }
+}
+
+// WithMaxLineSize sets the MaxLineSize option.
+func WithMaxLineSize(n int) Option {
+	return func(o *Options) { o.MaxLineSize = n }
+}
+
+// WithCollapseBlankLines sets the CollapseBlankLines option.
+func WithCollapseBlankLines(collapse bool) Option {
+	return func(o *Options) { o.CollapseBlankLines = collapse }
+}
+
+// WithHash sets the Hash option.
+func WithHash(h hash.Hash) Option {
+	return func(o *Options) { o.Hash = h }
+}
+
+// WithBinary sets the Binary option.
+func WithBinary(binary bool) Option {
+	return func(o *Options) { o.Binary = binary }
+}
+
+// WithAutoDetectLineEnding sets the AutoDetectLineEnding option.
+func WithAutoDetectLineEnding(detect bool) Option {
+	return func(o *Options) { o.AutoDetectLineEnding = detect }
+}
+
+// WithUnique sets the Unique option.
+func WithUnique(unique bool) Option {
+	return func(o *Options) { o.Unique = unique }
+}
+
+// WithTransform sets the Transform option.
+func WithTransform(fn func(line []byte) []byte) Option {
+	return func(o *Options) { o.Transform = fn }
+}
+
+// WithDelimiterLookback sets the DelimiterLookback option.
+func WithDelimiterLookback(n int) Option {
+	return func(o *Options) { o.DelimiterLookback = n }
+}
+
+// WithPosOffset sets the PosOffset option.
+func WithPosOffset(offset int) Option {
+	return func(o *Options) { o.PosOffset = offset }
+}
+
+// WithValidateJSON sets the ValidateJSON option.
+func WithValidateJSON(validate bool) Option {
+	return func(o *Options) { o.ValidateJSON = validate }
+}
+
+// WithSkipInvalidJSON sets the SkipInvalidJSON option.
+func WithSkipInvalidJSON(skip bool) Option {
+	return func(o *Options) { o.SkipInvalidJSON = skip }
+}
+
+// WithMaxBytesRead sets the MaxBytesRead option.
+func WithMaxBytesRead(n int64) Option {
+	return func(o *Options) { o.MaxBytesRead = n }
+}
@@ -0,0 +1,90 @@
+package backscanner
+
+import (
+	"hash/fnv"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icza/mighty"
+)
+
+func TestNewFunc(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	scanner := NewFunc(nil, 0)
+	eq(DefaultChunkSize, scanner.o.ChunkSize)
+	eq(DefaultMaxBufferSize, scanner.o.MaxBufferSize)
+	eq(byte(DefaultSeparator), scanner.o.Separator)
+
+	input := "a; ;b;c"
+	scanner = NewFunc(strings.NewReader(input), len(input),
+		WithChunkSize(2),
+		WithMaxBufferSize(100),
+		WithSeparator(';'),
+		WithTrimSpace(true),
+		WithSkipEmpty(true),
+		WithMaxLines(2),
+	)
+	eq(2, scanner.o.ChunkSize)
+	eq(100, scanner.o.MaxBufferSize)
+	for _, exp := range []string{"c", "b"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	_, _, err := scanner.Line()
+	eq(ErrMaxLines, err)
+
+	input = "a<>b"
+	scanner = NewFunc(strings.NewReader(input), len(input), WithDelimiter([]byte("<>")), WithKeepTerminator(true))
+	for _, exp := range []string{"b", "a<>"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	_, _, err = scanner.Line()
+	eq(io.EOF, err)
+}
+
+func TestOptionHelpers(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	h := fnv.New32a()
+	opts := []Option{
+		WithUnicodeLineBreaks(true), WithStripBOM(true), WithValidatePos(true),
+		WithAdaptiveChunk(true), WithGrowthChunks(2), WithPrefetch(true),
+		WithReadTimeout(time.Second), WithShrinkBuffer(true), WithInitialBufferSize(8),
+		WithMaxLineSize(10), WithCollapseBlankLines(true), WithHash(h), WithBinary(true),
+		WithAutoDetectLineEnding(true), WithUnique(true), WithDelimiterLookback(3),
+		WithPosOffset(5), WithValidateJSON(true), WithSkipInvalidJSON(true), WithMaxBytesRead(100),
+	}
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	deq(Options{
+		UnicodeLineBreaks: true, StripBOM: true, ValidatePos: true,
+		AdaptiveChunk: true, GrowthChunks: 2, Prefetch: true,
+		ReadTimeout: time.Second, ShrinkBuffer: true, InitialBufferSize: 8,
+		MaxLineSize: 10, CollapseBlankLines: true, Hash: h, Binary: true,
+		AutoDetectLineEnding: true, Unique: true, DelimiterLookback: 3,
+		PosOffset: 5, ValidateJSON: true, SkipInvalidJSON: true, MaxBytesRead: 100,
+	}, o)
+
+	// Function options:
+	var progress []int
+	input := "a\nb"
+	scanner := NewFunc(strings.NewReader(input), len(input),
+		WithTransform(func(line []byte) []byte { return append(line, '!') }),
+		WithOnProgress(func(pos int) { progress = append(progress, pos) }),
+		WithOnRead(func(offset int64, n int, dur time.Duration, err error) {}),
+		WithReadRetry(func(err error, attempt int) bool { return false }),
+		WithDecoder(nil),
+	)
+	line, _, err := scanner.Line()
+	eq("b!", line)
+	eq(nil, err)
+	deq([]int{0}, progress)
+}
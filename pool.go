@@ -0,0 +1,63 @@
+package backscanner
+
+import "sync"
+
+// BufferPool is a pool of byte slices that may be used by Scanners to obtain
+// their internal buffers, reducing allocations when many Scanners are used.
+// Implementations must be safe for concurrent use if shared between Scanners
+// used concurrently.
+type BufferPool interface {
+	// Get returns a byte slice from the pool of any capacity, or nil.
+	Get() []byte
+
+	// Put returns a byte slice to the pool.
+	Put(b []byte)
+}
+
+// SyncPool is a BufferPool backed by a sync.Pool.
+// The zero value is ready to use. A SyncPool must not be copied after first use.
+type SyncPool struct {
+	p sync.Pool
+}
+
+// Get implements BufferPool.Get().
+func (sp *SyncPool) Get() []byte {
+	if b, ok := sp.p.Get().(*[]byte); ok {
+		return *b
+	}
+	return nil
+}
+
+// Put implements BufferPool.Put().
+func (sp *SyncPool) Put(b []byte) {
+	sp.p.Put(&b)
+}
+
+// alloc returns a buffer of at least the given size, using BufferPool if set.
+func (s *Scanner) alloc(size int) []byte {
+	if s.o.BufferPool != nil {
+		b := s.o.BufferPool.Get()
+		if cap(b) >= size {
+			return b[:cap(b)]
+		}
+		if b != nil {
+			s.o.BufferPool.Put(b)
+		}
+	}
+	return make([]byte, size)
+}
+
+// free releases a buffer obtained from alloc, returning it to BufferPool if set.
+func (s *Scanner) free(b []byte) {
+	if s.o.BufferPool != nil && cap(b) > 0 {
+		s.o.BufferPool.Put(b)
+	}
+}
+
+// releaseBuffers returns the internal buffers to BufferPool if set.
+func (s *Scanner) releaseBuffers() {
+	if s.o.BufferPool != nil {
+		s.free(s.arr)
+		s.arr, s.buf = nil, nil
+	}
+}
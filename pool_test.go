@@ -0,0 +1,54 @@
+package backscanner
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/icza/mighty"
+)
+
+// countingPool is a BufferPool which counts Get hits and Put calls.
+type countingPool struct {
+	SyncPool
+	hits, puts int
+}
+
+func (cp *countingPool) Get() []byte {
+	b := cp.SyncPool.Get()
+	if b != nil {
+		cp.hits++
+	}
+	return b
+}
+
+func (cp *countingPool) Put(b []byte) {
+	cp.puts++
+	cp.SyncPool.Put(b)
+}
+
+func TestBufferPool(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	pool := &countingPool{}
+	input := "Line1\nLine2\nLine3"
+	scanner := NewString(input, &Options{ChunkSize: 4, BufferPool: pool})
+	for i := 0; i < 3; i++ {
+		for _, exp := range []string{"Line3", "Line2", "Line1"} {
+			line, _, err := scanner.Line()
+			eq(exp, line)
+			eq(nil, err)
+		}
+		_, _, err := scanner.Line()
+		eq(io.EOF, err)
+		scanner.Reset(strings.NewReader(input), len(input))
+	}
+	eq(true, pool.hits > 0) // Buffers are reused after Reset()
+	eq(nil, scanner.Close())
+	eq(true, pool.puts > 0)
+	eq(0, len(scanner.arr))
+
+	// Empty pool:
+	var sp SyncPool
+	eq(0, len(sp.Get()))
+}
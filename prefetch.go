@@ -0,0 +1,211 @@
+package backscanner
+
+import (
+	"errors"
+	"io"
+)
+
+// prefetchReq is a request to the prefetch goroutine to read a chunk.
+type prefetchReq struct {
+	r   io.ReaderAt
+	buf []byte
+	off int64
+}
+
+// prefetchRes is the result of a prefetchReq.
+type prefetchRes struct {
+	n     int
+	calls int
+	err   error
+}
+
+// prefetcher reads chunks ahead in a goroutine.
+type prefetcher struct {
+	reqs    chan prefetchReq // reqs delivers read requests to the goroutine
+	res     chan prefetchRes // res delivers the result of the pending request
+	done    chan struct{}    // done is closed when the goroutine exits
+	buf     []byte           // buf is the spare buffer chunks are prefetched into
+	off     int64            // off is the position of the pending request
+	size    int              // size is the size of the pending request
+	pending bool             // pending tells if there is a request in progress
+	ready   bool             // ready tells if the pending request completed, its result is in result
+	result  prefetchRes      // result is the result of the completed pending request
+}
+
+// newPrefetcher creates a prefetcher and starts its goroutine.
+func newPrefetcher() *prefetcher {
+	pf := &prefetcher{
+		reqs: make(chan prefetchReq),
+		res:  make(chan prefetchRes, 1),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(pf.done)
+		for req := range pf.reqs {
+			n, calls, err := readFull(req.r, req.buf, req.off)
+			pf.res <- prefetchRes{n, calls, err}
+		}
+	}()
+	return pf
+}
+
+// start starts prefetching size bytes at off from r.
+// There must be no pending request.
+func (pf *prefetcher) start(r io.ReaderAt, off int64, size int) {
+	if cap(pf.buf) < size {
+		pf.buf = make([]byte, size)
+	}
+	pf.off, pf.size, pf.pending = off, size, true
+	pf.reqs <- prefetchReq{r: r, buf: pf.buf[:size], off: off}
+}
+
+// take waits for the pending request (if any), and returns its result.
+// If it read p at off, the prefetched data is copied into p, and ok is true.
+func (pf *prefetcher) take(p []byte, off int64) (res prefetchRes, ok bool) {
+	if res, ok = pf.wait(); ok && pf.off == off && pf.size == len(p) {
+		copy(p, pf.buf[:res.n])
+		return res, true
+	}
+	return res, false
+}
+
+// wait waits for the pending request (if any) to complete, and returns its result.
+func (pf *prefetcher) wait() (res prefetchRes, ok bool) {
+	if !pf.pending {
+		return res, false
+	}
+	pf.settle()
+	pf.pending, pf.ready = false, false
+	return pf.result, true
+}
+
+// settle waits for the pending request (if any) to complete, keeping it
+// pending, so its result can still be taken.
+func (pf *prefetcher) settle() {
+	if pf.pending && !pf.ready {
+		pf.result, pf.ready = <-pf.res, true
+	}
+}
+
+// stop waits for the pending request (if any), and stops the goroutine.
+func (pf *prefetcher) stop() {
+	pf.wait()
+	close(pf.reqs)
+	<-pf.done
+}
+
+// readAt reads len(p) bytes at off from the input, using the prefetched data
+// if Prefetch is set and it is available. Failed reads are retried as long as
+// ReadRetry allows it.
+func (s *Scanner) readAt(p []byte, off int64) (n int, err error) {
+	ok := false
+	if s.pf != nil {
+		var res prefetchRes
+		res, ok = s.pf.take(p, off)
+		s.count(res.n, res.calls)
+		n, err = res.n, eofErr(res.err)
+	}
+	if !ok {
+		n, err = s.readFull(p, off)
+	}
+	for attempt := 1; s.retry(err, attempt); attempt++ {
+		n, err = s.readFull(p, off)
+	}
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF && err != ErrNilReader {
+		err = &ReadError{Pos: off + int64(s.o.PosOffset), Err: err}
+	}
+	return n, err
+}
+
+// readFull reads len(p) bytes at off from the input, and counts the read.
+func (s *Scanner) readFull(p []byte, off int64) (n int, err error) {
+	if s.r == nil {
+		return 0, ErrNilReader
+	}
+	var calls int
+	n, calls, err = readFull(s.input(), p, off)
+	s.count(n, calls)
+	return n, eofErr(err)
+}
+
+// readAside is like readFull(), but it is for reading data other than the
+// chunks (e.g. to inspect terminators): it waits for a pending prefetch to
+// complete first, so the input is not read concurrently.
+func (s *Scanner) readAside(p []byte, off int64) (n int, err error) {
+	if s.pf != nil {
+		s.pf.settle()
+	}
+	return s.readFull(p, off)
+}
+
+// eofErr returns the bare io.EOF or io.ErrUnexpectedEOF if err wraps them,
+// else err.
+func eofErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, io.EOF):
+		return io.EOF
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// retry tells if a read that failed with err is to be retried.
+func (s *Scanner) retry(err error, attempt int) bool {
+	if err == nil || err == io.EOF || err == io.ErrUnexpectedEOF || s.o.ReadRetry == nil {
+		return false
+	}
+	return s.o.ReadRetry(err, attempt)
+}
+
+// prefetch starts prefetching the chunk before pos if Prefetch is set.
+func (s *Scanner) prefetch() {
+	if !s.o.Prefetch || s.atMin() {
+		return
+	}
+	size := s.o.ChunkSize
+	if s.chunk > 0 {
+		size = s.chunk
+	}
+	if rem := s.pos - int64(s.o.MinPos); int64(size) > rem {
+		size = int(rem)
+	}
+	if s.pf == nil {
+		s.pf = newPrefetcher()
+	}
+	s.pf.start(s.input(), s.pos-int64(size), size)
+}
+
+// stopPrefetch stops the prefetch goroutine (if running).
+func (s *Scanner) stopPrefetch() {
+	if s.pf != nil {
+		res, _ := s.pf.wait()
+		s.count(res.n, res.calls)
+		s.pf.stop()
+		s.pf = nil
+	}
+}
+
+// readFull reads len(p) bytes at off from r, and also returns the number
+// of ReadAt calls made.
+//
+// ReadAt attempts to read full p, but unusual readers may return less
+// without an error (or with io.ErrUnexpectedEOF), so it reads until p is full
+// or an error occurs without making progress.
+func readFull(r io.ReaderAt, p []byte, off int64) (n, calls int, err error) {
+	for n < len(p) && err == nil {
+		var m int
+		m, err = r.ReadAt(p[n:], off+int64(n))
+		calls++
+		if m == 0 && err == nil {
+			err = io.ErrNoProgress
+		}
+		n += m
+		if err == io.ErrUnexpectedEOF && m > 0 && n < len(p) {
+			err = nil
+		}
+	}
+	return
+}
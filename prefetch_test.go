@@ -0,0 +1,122 @@
+package backscanner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/icza/mighty"
+)
+
+func TestPrefetch(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprint(strings.Repeat("x", i%13), i))
+	}
+	input := strings.Join(lines, "\n")
+
+	for _, o := range []*Options{
+		{ChunkSize: 1, Prefetch: true},
+		{ChunkSize: 7, Prefetch: true},
+		{ChunkSize: 7, Prefetch: true, AdaptiveChunk: true},
+		{ChunkSize: 1000, Prefetch: true},
+	} {
+		r := &countingReaderAt{r: strings.NewReader(input)}
+		scanner := NewOptions(r, len(input), o)
+		for i := len(lines) - 1; i >= 0; i-- {
+			line, _, err := scanner.Line()
+			eq(lines[i], line)
+			eq(nil, err)
+		}
+		_, _, err := scanner.Line()
+		eq(io.EOF, err)
+		eq(nil, scanner.Close())
+		eq(true, scanner.pf == nil)
+		if !o.AdaptiveChunk {
+			// Every prefetched chunk must be used:
+			eq((len(input)+o.ChunkSize-1)/o.ChunkSize, r.calls)
+		}
+	}
+}
+
+func TestPrefetchEarlyClose(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	goroutines := runtime.NumGoroutine()
+
+	input := strings.Repeat("line\n", 1000)
+	for i := 0; i < 10; i++ {
+		scanner := NewOptions(strings.NewReader(input), len(input), &Options{ChunkSize: 8, Prefetch: true})
+		for j := 0; j < i; j++ {
+			_, _, err := scanner.Line()
+			eq(nil, err)
+		}
+		eq(nil, scanner.Close())
+	}
+
+	// Goroutines exit before Close() returns, but allow the runtime to settle:
+	for i := 0; i < 100 && runtime.NumGoroutine() > goroutines; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	eq(goroutines, runtime.NumGoroutine())
+}
+
+var errOverlap = errors.New("overlapping ReadAt")
+
+// exclusiveReaderAt is an io.ReaderAt that fails if ReadAt is called
+// concurrently.
+type exclusiveReaderAt struct {
+	r      io.ReaderAt
+	active int32
+}
+
+func (e *exclusiveReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	defer atomic.AddInt32(&e.active, -1)
+	if atomic.AddInt32(&e.active, 1) > 1 {
+		return 0, errOverlap
+	}
+	time.Sleep(time.Millisecond) // Give overlapping calls a chance
+	return e.r.ReadAt(p, off)
+}
+
+func TestPrefetchSideReads(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := strings.Repeat("line\n", 100)
+	r := &exclusiveReaderAt{r: strings.NewReader(input)}
+	scanner := NewOptions(r, len(input), &Options{ChunkSize: 8, Prefetch: true})
+	defer scanner.Close()
+	for i := 0; i < 10; i++ {
+		_, _, err := scanner.Line()
+		eq(nil, err)
+		// Reads the terminator at the starting position while prefetching:
+		eq(true, scanner.HadTrailingNewline())
+		scanner.trailingKnown = false
+	}
+}
+
+func TestPrefetchTruncated(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := strings.Repeat("line\n", 20)
+	r := &exclusiveReaderAt{r: strings.NewReader(input)}
+	scanner := NewOptions(r, len(input), &Options{ChunkSize: 8, Prefetch: true, MinPos: 2})
+	defer scanner.Close()
+	for {
+		line, pos, err := scanner.Line()
+		if err == io.EOF {
+			break
+		}
+		eq(nil, err)
+		// Reads the bytes before MinPos for the first line of the region:
+		eq(pos == 2, scanner.Truncated())
+		eq(pos == 2, line == "ne")
+	}
+}
@@ -0,0 +1,170 @@
+package backscanner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// PosEnd may be passed as the position to NewReadSeeker() to start scanning
+// at the end of the input.
+const PosEnd = -1
+
+// NewString returns a new Scanner that reads the given string starting at its
+// end, with the given Options (which may be nil).
+func NewString(str string, o *Options) *Scanner {
+	return NewOptions(strings.NewReader(str), len(str), o)
+}
+
+// NewBytes returns a new Scanner that reads the given byte slice starting at its
+// end, with the given Options (which may be nil).
+// The content of b must not be modified while it is being scanned.
+//
+// b is scanned in place (it is not copied into the internal buffer), so
+// returned line slices share data with b (unless they are decoded or
+// transformed, e.g. due to Encoding or Transform). The input of the Scanner
+// implements io.ReaderAt and has a Size() int64 and a Bytes() []byte method.
+func NewBytes(b []byte, o *Options) *Scanner {
+	return NewOptions(memReaderAt(b), len(b), o)
+}
+
+// memReaderAt is an io.ReaderAt of an in-memory input. The Scanner scans its
+// content in place.
+type memReaderAt []byte
+
+// ReadAt implements io.ReaderAt.
+func (m memReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= int64(len(m)) {
+		return 0, io.EOF
+	}
+	if n = copy(p, m[off:]); n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Size returns the size of the input.
+func (m memReaderAt) Size() int64 {
+	return int64(len(m))
+}
+
+// Bytes returns the content of the input.
+func (m memReaderAt) Bytes() []byte {
+	return m
+}
+
+// inMemory returns the content of the input if it is in memory (a
+// memReaderAt). Chunks of such inputs are not read (copied) into the internal
+// buffer, the content is scanned in place. Other inputs with a Bytes() method
+// are not trusted to return their full content.
+func (s *Scanner) inMemory() []byte {
+	if m, ok := s.r.(memReaderAt); ok {
+		return m
+	}
+	return nil
+}
+
+// NewReadSeeker returns a new Scanner that reads from an io.ReadSeeker, with the
+// given Options (which may be nil).
+// If pos is PosEnd, the end of the input is determined by seeking to its end.
+//
+// The io.ReadSeeker is wrapped in an io.ReaderAt which implements ReadAt using
+// Seek and Read. Reads are serialized using a mutex, so the position of rs is
+// not shared between concurrent reads.
+func NewReadSeeker(rs io.ReadSeeker, pos int, o *Options) (*Scanner, error) {
+	if pos == PosEnd {
+		end, err := rs.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		pos = int(end)
+	}
+	return NewOptions(&readSeekerAt{rs: rs}, pos, o), nil
+}
+
+// NewFromFile opens the named file and returns a new Scanner positioned at its
+// end, with the given Options (which may be nil).
+// The file is closed when Scanner.Close() is called.
+func NewFromFile(name string, o *Options) (*Scanner, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return NewOptions(f, int(fi.Size()), o), nil
+}
+
+// NewSection returns a new Scanner that reads the section of r starting at
+// start with the given length, beginning at its end, with the given Options
+// (which may be nil). The Scanner does not read outside of the section,
+// and returned positions are positions in r (not relative to the section).
+//
+// The MinPos option is overridden with start.
+func NewSection(r io.ReaderAt, start, length int64, o *Options) *Scanner {
+	var so Options
+	if o != nil {
+		so = *o
+	}
+	so.MinPos = int(start)
+	return New64(r, start+length, &so)
+}
+
+// inputSize returns the size of the input if it can be determined.
+// r may have a Size() int64 method, or it may implement io.Seeker (in which case
+// its offset is restored).
+func inputSize(r io.ReaderAt) (size int64, ok bool) {
+	switch v := r.(type) {
+	case interface{ Size() int64 }:
+		return v.Size(), true
+	case io.Seeker:
+		cur, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		size, err := v.Seek(0, io.SeekEnd)
+		if _, err2 := v.Seek(cur, io.SeekStart); err != nil || err2 != nil {
+			return 0, false
+		}
+		return size, true
+	}
+	return 0, false
+}
+
+// readSeekerAt implements io.ReaderAt using an io.ReadSeeker.
+type readSeekerAt struct {
+	mu sync.Mutex    // mu serializes reads
+	rs io.ReadSeeker // rs is the wrapped io.ReadSeeker
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *readSeekerAt) ReadAt(p []byte, off int64) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err = r.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err = io.ReadFull(r.rs, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Close closes the wrapped io.ReadSeeker if it implements io.Closer.
+func (r *readSeekerAt) Close() error {
+	if c, ok := r.rs.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
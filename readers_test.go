@@ -0,0 +1,257 @@
+package backscanner
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/icza/mighty"
+)
+
+func TestNewStringBytes(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\nLine2"
+	for _, scanner := range []*Scanner{NewString(input, nil), NewBytes([]byte(input), &Options{ChunkSize: 2})} {
+		line, pos, err := scanner.Line()
+		eq("Line2", line)
+		eq(6, pos)
+		eq(nil, err)
+		line, pos, err = scanner.Line()
+		eq("Line1", line)
+		eq(0, pos)
+		eq(nil, err)
+		_, _, err = scanner.Line()
+		eq(io.EOF, err)
+	}
+}
+
+func TestInMemory(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	type result struct {
+		line string
+		pos  int
+		err  error
+	}
+
+	// scan returns all results of the scanner, including the first error:
+	scan := func(scanner *Scanner) (results []result) {
+		for {
+			line, pos, err := scanner.Line()
+			results = append(results, result{line, pos, err})
+			if err != nil {
+				return
+			}
+			if pos%3 == 0 {
+				eq(nil, scanner.UnreadLine())
+				c := scanner.Clone()
+				line2, pos2, err := c.Line()
+				eq(result{line, pos, nil}, result{line2, pos2, err})
+				scanner.Line()
+			}
+		}
+	}
+
+	// In-memory inputs must be scanned just like other inputs:
+	inputs := []string{"", "\n", "Line1\r\nLine2\n\nLine4", "a\n" + strings.Repeat("long", 10) + "\nb\n", "x<br>y<br><br"}
+	options := []Options{
+		{},
+		{KeepTerminator: true},
+		{Delimiter: []byte("<br>")},
+		{MaxBufferSize: 16},
+		{MinPos: 3},
+		{AdaptiveChunk: true, ShrinkBuffer: true},
+		{SplitMode: Words},
+		{Transform: func(line []byte) []byte {
+			if len(line) > 0 {
+				line[0] = '_'
+			}
+			return line
+		}},
+	}
+	for _, input := range inputs {
+		for _, o := range options {
+			for chunkSize := 1; chunkSize <= 6; chunkSize++ {
+				o.ChunkSize = chunkSize
+				for _, pos := range []int{len(input), len(input) + 1} {
+					generic := NewOptions(strings.NewReader(input), pos, &o)
+					exps := scan(generic)
+					b := []byte(input)
+					scanner := NewOptions(memReaderAt(b), pos, &o)
+					deq(exps, scan(scanner))
+					eq(input, string(b))
+					if pos == len(input) {
+						eq(generic.Stats().BytesRead, scanner.Stats().BytesRead)
+					}
+				}
+			}
+		}
+	}
+
+	// Lines share data with the input:
+	b := []byte("Line1\nLine2")
+	scanner := NewBytes(b, &Options{ChunkSize: 2})
+	line, pos, err := scanner.LineBytes()
+	eq("Line2", string(line))
+	eq(nil, err)
+	eq(&b[pos], &line[0])
+	eq(0, len(scanner.arr))
+
+	// Also with Transform, which gets a copy:
+	scanner = NewBytes(b, &Options{Transform: bytes.ToLower})
+	line, _, err = scanner.LineBytes()
+	eq("line2", string(line))
+	eq(nil, err)
+	eq("Line1\nLine2", string(b))
+	eq(0, len(scanner.arr))
+
+	// Other inputs with a Bytes() method are read:
+	scanner = NewOptions(bytesReaderAt{strings.NewReader("Line1\nLine2")}, 11, nil)
+	line, _, err = scanner.LineBytes()
+	eq("Line2", string(line))
+	eq(nil, err)
+	eq(true, len(scanner.arr) > 0)
+}
+
+// bytesReaderAt has a Bytes() method not returning the content of the input.
+type bytesReaderAt struct {
+	io.ReaderAt
+}
+
+func (bytesReaderAt) Bytes() []byte { return []byte("other") }
+
+func BenchmarkInMemory(b *testing.B) {
+	input := []byte(strings.Repeat("2006-01-02 15:04:05 INFO some log message\n", 10000))
+	inputs := []struct {
+		name string
+		r    io.ReaderAt
+	}{
+		{"generic", bytes.NewReader(input)},
+		{"in-memory", memReaderAt(input)},
+	}
+
+	for _, in := range inputs {
+		b.Run(in.name, func(b *testing.B) {
+			scanner := NewOptions(in.r, len(input), nil)
+			b.SetBytes(int64(len(input)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				scanner.Reset(in.r, len(input))
+				for {
+					if _, _, err := scanner.LineBytes(); err != nil {
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+// readSeeker hides all methods of the wrapped io.ReadSeeker but Read and Seek.
+type readSeeker struct {
+	io.ReadSeeker
+}
+
+func TestNewReadSeeker(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\nLine2\nLine3"
+	for _, pos := range []int{PosEnd, len(input)} {
+		scanner, err := NewReadSeeker(readSeeker{strings.NewReader(input)}, pos, &Options{ChunkSize: 4})
+		eq(nil, err)
+		for _, exp := range []string{"Line3", "Line2", "Line1"} {
+			line, _, err := scanner.Line()
+			eq(exp, line)
+			eq(nil, err)
+		}
+		_, _, err = scanner.Line()
+		eq(io.EOF, err)
+	}
+
+	// Starting in the middle:
+	scanner, err := NewReadSeeker(readSeeker{strings.NewReader(input)}, 11, nil)
+	eq(nil, err)
+	line, pos, err := scanner.Line()
+	eq("Line2", line)
+	eq(6, pos)
+	eq(nil, err)
+}
+
+func TestNewFromFile(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	name := filepath.Join(t.TempDir(), "test.log")
+	eq(nil, os.WriteFile(name, []byte("Line1\nLine2\n"), 0644))
+
+	scanner, err := NewFromFile(name, nil)
+	eq(nil, err)
+	for _, exp := range []string{"", "Line2", "Line1"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	eq(nil, scanner.Close())
+	eq(true, errors.Is(scanner.r.(*os.File).Close(), os.ErrClosed))
+
+	_, err = NewFromFile(filepath.Join(t.TempDir(), "missing.log"), nil)
+	eq(true, errors.Is(err, os.ErrNotExist))
+}
+
+func TestValidatePos(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	name := filepath.Join(t.TempDir(), "test.log")
+	eq(nil, os.WriteFile(name, []byte("Line1\nLine2"), 0644))
+	f, err := os.Open(name)
+	eq(nil, err)
+	defer f.Close()
+
+	input := "Line1\nLine2"
+	for _, r := range []io.ReaderAt{strings.NewReader(input), io.NewSectionReader(strings.NewReader(input), 0, 11), f} {
+		scanner := NewOptions(r, 100, &Options{ValidatePos: true})
+		line, pos, err := scanner.Line()
+		eq("Line2", line)
+		eq(6, pos)
+		eq(nil, err)
+
+		scanner = NewOptions(r, 100, nil)
+		_, _, err = scanner.Line()
+		eq(ErrPosBeyondEnd, err)
+	}
+	offset, err := f.Seek(0, io.SeekCurrent)
+	eq(int64(0), offset)
+	eq(nil, err)
+
+	// Size cannot be determined:
+	_, _, err = NewOptions(shortReaderAt{input, 3}, 100, &Options{ValidatePos: true}).Line()
+	eq(ErrPosBeyondEnd, err)
+}
+
+func TestNewSection(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "head\nLine1\nLine2\ntail"
+	r := &countingReaderAt{r: strings.NewReader(input)}
+	scanner := NewSection(r, 5, 12, &Options{ChunkSize: 3, MinPos: 100})
+	line, pos, err := scanner.Line()
+	eq("", line)
+	eq(17, pos)
+	eq(nil, err)
+	line, pos, err = scanner.Line()
+	eq("Line2", line)
+	eq(11, pos)
+	eq(nil, err)
+	line, pos, err = scanner.Line()
+	eq("Line1", line)
+	eq(5, pos)
+	eq(nil, err)
+	_, _, err = scanner.Line()
+	eq(io.EOF, err)
+	eq(4, r.calls)
+}
@@ -0,0 +1,335 @@
+package backscanner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// ReverseSplitFunc is the signature of the split function used to tokenize the
+// input backward. It is the reverse counterpart of bufio.SplitFunc.
+//
+// The arguments are the unprocessed data (which is at the end of the remaining
+// input) and a flag, atEOF, that reports whether data starts at the beginning
+// of the input (so no more data can be read before it).
+// The return values are the number of bytes to consume from the end of data,
+// the next token to return (if any), and an error (if any).
+//
+// If the function returns an error, scanning stops, and the error is returned.
+// If the data does not yet hold a complete token, the function should return
+// (0, nil, nil) to signal that more data should be read (or if atEOF is true,
+// that there are no more tokens). If a token is returned, consumedFromEnd must
+// be positive.
+//
+// The reported position of a token is its position in the input if it is a
+// subslice of data, else the position of the end of the consumed data minus
+// the length of the token.
+// Split functions operate on the raw (undecoded) input, the Encoding and
+// the Decoder are applied to the returned tokens.
+type ReverseSplitFunc func(data []byte, atEOF bool) (consumedFromEnd int, token []byte, err error)
+
+var (
+	// ErrBadConsumed indicates that a split function returned an invalid consumed count
+	ErrBadConsumed = errors.New("split function returned invalid consumed count")
+)
+
+// SplitMode tells what units the input is split into.
+type SplitMode int
+
+// Supported split modes.
+const (
+	// Lines splits the input into lines (this is the default).
+	Lines SplitMode = iota
+
+	// Paragraphs splits the input into paragraphs: runs of non-blank lines
+	// separated by one or more blank lines. Blank lines are not returned,
+	// the returned paragraphs contain their internal line terminators but not
+	// the terminator of their last line.
+	Paragraphs
+
+	// Words splits the input into space-separated words using ScanWordsReverse.
+	Words
+)
+
+// Split sets the split function for the Scanner, which overrides the
+// Separator, Delimiter, UnicodeLineBreaks, KeepTerminator and SplitMode options.
+// Split must be called before scanning.
+func (s *Scanner) Split(split ReverseSplitFunc) {
+	s.split = split
+}
+
+// ScanLinesReverse is a ReverseSplitFunc that returns each line of text,
+// stripped of any trailing end-of-line marker, like the default line splitting
+// of the Scanner. Unlike bufio.ScanLines, the last line of the input is
+// returned even if it's empty (the input ends with a newline), but an empty
+// first line is not.
+func ScanLinesReverse(data []byte, atEOF bool) (consumedFromEnd int, token []byte, err error) {
+	if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+		return len(data) - i, dropCR(data[i+1:]), nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), dropCR(data), nil
+	}
+	// Request more data (or report that there are no more tokens).
+	return 0, nil, nil
+}
+
+// ScanWordsReverse is a ReverseSplitFunc that returns each space-separated word
+// of text, with surrounding spaces deleted. It will never return an empty string.
+// The definition of space is set by unicode.IsSpace, just like with bufio.ScanWords.
+func ScanWordsReverse(data []byte, atEOF bool) (consumedFromEnd int, token []byte, err error) {
+	// Skip trailing spaces.
+	end := len(data)
+	for end > 0 {
+		r, width := utf8.DecodeLastRune(data[:end])
+		if !isSpace(r) {
+			break
+		}
+		end -= width
+	}
+	// Scan until space, marking start of word.
+	for start := end; start > 0; {
+		r, width := utf8.DecodeLastRune(data[:start])
+		if isSpace(r) {
+			return len(data) - start, data[start:end], nil
+		}
+		start -= width
+	}
+	// The word may continue before data (or data starts at the beginning of the input).
+	if atEOF && end > 0 {
+		return len(data), data[:end], nil
+	}
+	// Drop trailing spaces, and request more data.
+	return len(data) - end, nil, nil
+}
+
+// ScanCSVReverse is a ReverseSplitFunc that returns each record of CSV data
+// (as defined by RFC 4180), stripped of its trailing end-of-line marker.
+// Newlines inside quoted fields do not end a record, so a returned record may
+// span multiple lines. Records are returned as they are in the input (fields
+// are not parsed), they may be parsed e.g. using encoding/csv. Empty lines are
+// skipped, just like encoding/csv does.
+//
+// A newline ends a record if the number of quotes ('"') after it up to the end
+// of the record is even: a newline inside a quoted field is preceded by an odd
+// number of quotes in a valid record, so it's followed by an odd number of
+// quotes. Thus only the data of the record being scanned is needed to find its
+// start, which is determined once the preceding newline is read. If the input
+// is not valid CSV (e.g. a quoted field is not closed), records may be split
+// at the wrong newlines.
+func ScanCSVReverse(data []byte, atEOF bool) (consumedFromEnd int, token []byte, err error) {
+	quotes := 0
+	for i := len(data) - 1; i >= 0; i-- {
+		switch data[i] {
+		case '"':
+			quotes++
+		case '\n':
+			if quotes%2 == 0 {
+				if record := dropCR(data[i+1:]); len(record) > 0 {
+					return len(data) - i, record, nil
+				}
+				// Skip empty line:
+				return len(data) - i, nil, nil
+			}
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), dropCR(data), nil
+	}
+	// Request more data (or report that there are no more tokens).
+	return 0, nil, nil
+}
+
+// isSpace reports whether the character is a Unicode white space character.
+// It's the same as in package bufio.
+func isSpace(r rune) bool {
+	if r <= '\u00FF' {
+		// Obvious ASCII ones: \t through \r plus space. Plus two Latin-1 oddballs.
+		switch r {
+		case ' ', '\t', '\n', '\v', '\f', '\r':
+			return true
+		case '\u0085', '\u00A0':
+			return true
+		}
+		return false
+	}
+	// High-valued ones.
+	if '\u2000' <= r && r <= '\u200a' {
+		return true
+	}
+	switch r {
+	case '\u1680', '\u2028', '\u2029', '\u202f', '\u205f', '\u3000':
+		return true
+	}
+	return false
+}
+
+// splitToken returns the next token from the input using the split function,
+// and its absolute byte-position.
+func (s *Scanner) splitToken(ctx context.Context) (token []byte, pos int64, err error) {
+	for {
+		atEOF := s.atMin()
+		consumed, token, err := s.split(s.buf, atEOF)
+		if err != nil {
+			s.err = err
+			return nil, 0, err
+		}
+		if consumed < 0 || consumed > len(s.buf) || (consumed == 0 && token != nil) {
+			s.err = ErrBadConsumed
+			return nil, 0, s.err
+		}
+		if consumed > 0 {
+			start := len(s.buf) - consumed
+			pos = s.pos + int64(start+tokenOffset(s.buf[start:], token))
+			s.last, s.ltail, s.clean = consumed, s.tail, 0
+			s.buf = s.buf[:start]
+			if token != nil {
+				s.emit()
+				token, err = s.finish(token, pos)
+				return token, pos, err
+			}
+			continue
+		}
+		if atEOF {
+			if s.nextPart() {
+				continue
+			}
+			s.err = io.EOF
+			return nil, 0, s.err
+		}
+		// Need more data:
+		if err = ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		if s.readMore(); s.err != nil {
+			return nil, 0, s.err
+		}
+	}
+}
+
+// tokenOffset returns the offset of token in the consumed data. If token is
+// not a subslice of consumed, it is assumed to be at its end.
+func tokenOffset(consumed, token []byte) int {
+	if cap(token) > 0 && cap(token) <= cap(consumed) {
+		off := cap(consumed) - cap(token)
+		if off <= len(consumed) && &consumed[:cap(consumed)][off] == &token[:1][0] {
+			return off
+		}
+	}
+	return len(consumed) - len(token)
+}
+
+// dropCR drops a terminal \r from the data.
+func dropCR(data []byte) []byte {
+	if len(data) > 0 && data[len(data)-1] == '\r' {
+		return data[0 : len(data)-1]
+	}
+	return data
+}
+
+// PrevRune returns the previous UTF-8 encoded rune of the input, its size in
+// bytes and its absolute byte-position, and advances the Scanner before it.
+// If the encoding is invalid, it returns (utf8.RuneError, 1), matching the
+// behavior of utf8.DecodeLastRune(). After the first rune of the input,
+// subsequent calls report io.EOF.
+//
+// PrevRune may be mixed with line scanning: lines returned after it end
+// where the last returned rune started.
+// The input is always decoded as UTF-8, the Encoding option is not used.
+func (s *Scanner) PrevRune() (r rune, size int, pos int, err error) {
+	s.canUnread = false
+	if s.err == io.EOF {
+		s.nextPart()
+	}
+	if s.err != nil {
+		return utf8.RuneError, 0, 0, s.err
+	}
+
+	for {
+		if len(s.buf) > 0 {
+			r, size = utf8.DecodeLastRune(s.buf)
+			// A valid rune cannot change by reading more, but an invalid one
+			// may be the end of a multi-byte rune straddling the chunk boundary:
+			if r != utf8.RuneError || size > 1 || len(s.buf) >= utf8.UTFMax || s.atMin() {
+				s.buf, s.clean = s.buf[:len(s.buf)-size], 0
+				if s.tail -= size; s.tail < 0 {
+					s.tail = 0
+				}
+				return r, size, int(s.pos) + len(s.buf) + s.o.PosOffset, nil
+			}
+		} else if s.atMin() {
+			if s.nextPart() {
+				continue
+			}
+			s.err = io.EOF
+			return utf8.RuneError, 0, 0, s.err
+		}
+		if s.readMore(); s.err != nil {
+			return utf8.RuneError, 0, 0, s.err
+		}
+	}
+}
+
+// paragraph returns the next paragraph from the input and its absolute
+// byte-position.
+func (s *Scanner) paragraph(ctx context.Context) (par []byte, pos int64, err error) {
+	for {
+		if start, end, ok := s.lastParagraph(s.err == io.EOF); ok {
+			pos = s.pos + int64(start)
+			par = s.buf[start:end]
+			s.last, s.ltail, s.clean = len(s.buf)-start, s.tail, 0
+			s.buf = s.buf[:start]
+			s.emit()
+			par, err = s.finish(par, pos)
+			return par, pos, err
+		}
+		if s.err != nil {
+			if s.err == io.EOF && s.nextPart() {
+				continue
+			}
+			return nil, 0, s.err
+		}
+		// Need more data:
+		if err = ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		s.readMore()
+		if s.err != nil && s.err != io.EOF {
+			return nil, 0, s.err
+		}
+	}
+}
+
+// lastParagraph returns the start and end index of the last complete paragraph
+// in buf. If atEOF is true, the beginning of buf is the beginning of the input.
+func (s *Scanner) lastParagraph(atEOF bool) (start, end int, ok bool) {
+	end = -1
+	for i := len(s.buf); ; {
+		// Line between j and i:
+		j, n := s.lastSep(s.buf[:i])
+		if j < 0 && !atEOF {
+			return 0, 0, false
+		}
+		lineStart := j + n
+		if j < 0 {
+			lineStart = 0
+		}
+		blank := len(s.dropCR(s.buf[lineStart:i])) == 0
+
+		switch {
+		case end < 0 && !blank:
+			end = i
+		case end >= 0 && blank:
+			return start, end, true
+		}
+		if !blank {
+			start = lineStart
+		}
+		if j < 0 {
+			return start, end, end >= 0
+		}
+		i = j
+	}
+}
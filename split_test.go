@@ -0,0 +1,273 @@
+package backscanner
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/icza/mighty"
+)
+
+func TestParagraphs(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		par string
+		pos int
+	}
+
+	cases := []struct {
+		input string
+		exps  []result
+	}{
+		{input: ""},
+		{input: "\n\r\n\n"},
+		{input: "single", exps: []result{{"single", 0}}},
+		{
+			input: "\n\nP1 L1\nP1 L2\n\n\n\nP2 L1\r\nP2 L2\r\n\r\nP3\n\n\n",
+			exps: []result{
+				{"P3", 33},
+				{"P2 L1\r\nP2 L2", 17},
+				{"P1 L1\nP1 L2", 2},
+			},
+		},
+		{
+			input: "P1\n\nP2 L1\n \nP2 L3",
+			exps: []result{
+				{"P2 L1\n \nP2 L3", 4},
+				{"P1", 0},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		for chunkSize := 1; chunkSize <= len(c.input)+1; chunkSize++ {
+			scanner := NewString(c.input, &Options{ChunkSize: chunkSize, SplitMode: Paragraphs})
+			for _, exp := range c.exps {
+				par, pos, err := scanner.Line()
+				eq(exp.par, par)
+				eq(exp.pos, pos)
+				eq(nil, err)
+			}
+			_, _, err := scanner.Line()
+			eq(io.EOF, err)
+			eq(len(c.exps), scanner.LineNumber())
+		}
+	}
+
+	// Peek in paragraph mode:
+	scanner := NewString("P1\n\nP2\n", &Options{ChunkSize: 2, SplitMode: Paragraphs})
+	for _, exp := range []string{"P2", "P1"} {
+		par, _, err := scanner.Peek()
+		eq(exp, string(par))
+		eq(nil, err)
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+}
+
+func TestScanLinesReverse(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	// Must behave the same as the default line splitting:
+	for _, input := range []string{"", "a", "\n", "\na\n", "Line1\r\nLine2\n\nLine3\r", "\n\n\r\n"} {
+		for chunkSize := 1; chunkSize <= len(input)+1; chunkSize++ {
+			scanner := NewString(input, &Options{ChunkSize: chunkSize})
+			scanner.Split(ScanLinesReverse)
+			exps := NewString(input, nil)
+			for {
+				expLine, expPos, expErr := exps.Line()
+				line, pos, err := scanner.Line()
+				eq(expLine, line)
+				eq(expPos, pos)
+				eq(expErr, err)
+				if err != nil {
+					break
+				}
+			}
+		}
+	}
+
+	// Unlike bufio.ScanLines, an empty last line is returned:
+	scanner := NewString("a\nb\n", nil)
+	scanner.Split(ScanLinesReverse)
+	lines, _, err := scanner.Lines(10)
+	deq([]string{"", "b", "a"}, lines)
+	eq(nil, err)
+}
+
+func TestScanWordsReverse(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		word string
+		pos  int
+	}
+
+	input := "  Hello,\tbrave\u00a0new\u2028wörld  \n"
+	exps := []result{{"wörld", 22}, {"new", 16}, {"brave", 9}, {"Hello,", 2}}
+	for chunkSize := 1; chunkSize <= len(input)+1; chunkSize++ {
+		scanner := NewString(input, &Options{ChunkSize: chunkSize})
+		scanner.Split(ScanWordsReverse)
+		for _, exp := range exps {
+			word, pos, err := scanner.Line()
+			eq(exp.word, word)
+			eq(exp.pos, pos)
+			eq(nil, err)
+		}
+		_, _, err := scanner.Line()
+		eq(io.EOF, err)
+	}
+}
+
+func TestScanCSVReverse(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	type result struct {
+		record string
+		pos    int
+	}
+
+	cases := []struct {
+		input string
+		exps  []result
+	}{
+		{"", nil},
+		{"\n\r\n", nil},
+		{"a,b\nc,d", []result{{"c,d", 4}, {"a,b", 0}}},
+		{"a,b\r\nc,d\r\n", []result{{"c,d", 5}, {"a,b", 0}}},
+		{"a,b\n\nc,d\n\n", []result{{"c,d", 5}, {"a,b", 0}}},
+		{"h1,h2\n1,\"x\ny\"\n2,\"\n\"\n", []result{{"2,\"\n\"", 14}, {"1,\"x\ny\"", 6}, {"h1,h2", 0}}},
+		{"\"a\n\"\"b\n\"\"\",\"\n\"\nc", []result{{"c", 15}, {"\"a\n\"\"b\n\"\"\",\"\n\"", 0}}},
+		{"\"\"\"\n\"\"\"\n", []result{{"\"\"\"\n\"\"\"", 0}}},
+	}
+
+	for _, c := range cases {
+		for chunkSize := 1; chunkSize <= len(c.input)+1; chunkSize++ {
+			scanner := NewString(c.input, &Options{ChunkSize: chunkSize})
+			scanner.Split(ScanCSVReverse)
+			for _, exp := range c.exps {
+				record, pos, err := scanner.Line()
+				eq(exp, result{record, pos})
+				eq(nil, err)
+			}
+			_, _, err := scanner.Line()
+			eq(io.EOF, err)
+		}
+	}
+
+	// Returned records can be parsed with encoding/csv:
+	input := "name,comment\nbob,\"multi\nline, \"\"quoted\"\"\"\n"
+	scanner := NewString(input, nil)
+	scanner.Split(ScanCSVReverse)
+	record, pos, err := scanner.Line()
+	eq(nil, err)
+	eq(13, pos)
+	fields, err := csv.NewReader(strings.NewReader(record)).Read()
+	eq(nil, err)
+	deq([]string{"bob", "multi\nline, \"quoted\""}, fields)
+}
+
+func TestWords(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	// Words spanning multiple chunks must not be cut:
+	input := "\tfirst  verylongword\r\n x\n\nlast\t  "
+	for chunkSize := 1; chunkSize <= len(input)+1; chunkSize++ {
+		scanner := NewString(input, &Options{ChunkSize: chunkSize, SplitMode: Words})
+		var words []string
+		var poss []int
+		for scanner.Scan() {
+			words = append(words, scanner.Text())
+		}
+		eq(nil, scanner.Err())
+		deq([]string{"last", "x", "verylongword", "first"}, words)
+
+		scanner.Reset(strings.NewReader(input), len(input))
+		for {
+			_, pos, err := scanner.Line()
+			if err != nil {
+				break
+			}
+			poss = append(poss, pos)
+		}
+		deq([]int{26, 23, 8, 1}, poss)
+	}
+
+	// A word must fit into the buffer:
+	scanner := NewString("a verylongword b", &Options{ChunkSize: 2, MaxBufferSize: 8, SplitMode: Words})
+	word, _, err := scanner.Line()
+	eq("b", word)
+	eq(nil, err)
+	_, _, err = scanner.Line()
+	eq(true, errors.Is(err, ErrLongLine))
+}
+
+func TestPrevRune(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		r    rune
+		size int
+		pos  int
+	}
+
+	input := "a\xffé€\xe2\x82😀\n"
+	exps := []result{
+		{'\n', 1, 13}, {'😀', 4, 9}, {utf8.RuneError, 1, 8}, {utf8.RuneError, 1, 7},
+		{'€', 3, 4}, {'é', 2, 2}, {utf8.RuneError, 1, 1}, {'a', 1, 0},
+	}
+	for chunkSize := 1; chunkSize <= len(input)+1; chunkSize++ {
+		scanner := NewString(input, &Options{ChunkSize: chunkSize})
+		for _, exp := range exps {
+			r, size, pos, err := scanner.PrevRune()
+			eq(exp.r, r)
+			eq(exp.size, size)
+			eq(exp.pos, pos)
+			eq(nil, err)
+		}
+		_, _, _, err := scanner.PrevRune()
+		eq(io.EOF, err)
+	}
+
+	// Mixed with line scanning:
+	scanner := NewString("ab\ncd", &Options{ChunkSize: 1})
+	r, _, _, err := scanner.PrevRune()
+	eq('d', r)
+	eq(nil, err)
+	line, pos, err := scanner.Line()
+	eq("c", line)
+	eq(3, pos)
+	eq(nil, err)
+	r, _, pos, err = scanner.PrevRune()
+	eq('b', r)
+	eq(1, pos)
+	eq(nil, err)
+}
+
+func TestSplitErrors(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	myErr := errors.New("my error")
+	cases := []struct {
+		split ReverseSplitFunc
+		err   error
+	}{
+		{func(data []byte, atEOF bool) (int, []byte, error) { return 0, nil, myErr }, myErr},
+		{func(data []byte, atEOF bool) (int, []byte, error) { return -1, nil, nil }, ErrBadConsumed},
+		{func(data []byte, atEOF bool) (int, []byte, error) { return len(data) + 1, nil, nil }, ErrBadConsumed},
+		{func(data []byte, atEOF bool) (int, []byte, error) { return 0, []byte{}, nil }, ErrBadConsumed},
+	}
+	for _, c := range cases {
+		scanner := NewString("some input", nil)
+		scanner.Split(c.split)
+		_, _, err := scanner.Line()
+		eq(c.err, err)
+		_, _, err = scanner.Line()
+		eq(c.err, err)
+	}
+}
@@ -0,0 +1,168 @@
+package backscanner
+
+import "io"
+
+// ScannerState is the state of a Scanner which can be used to continue
+// scanning later with another Scanner (e.g. in a subsequent HTTP request).
+// It has exported fields only, so it may be serialized e.g. with encoding/json.
+type ScannerState struct {
+	// Pos is the position up to which the input is yet to be scanned.
+	Pos int64
+
+	// Tail is the length of the kept terminator before Pos if KeepTerminator is set.
+	Tail int
+
+	// EOF tells if the start of the input has been reached.
+	EOF bool
+
+	// Lines is the number of lines returned so far.
+	Lines int
+}
+
+// State returns the state of the Scanner.
+func (s *Scanner) State() ScannerState {
+	return ScannerState{
+		Pos:   s.pos + int64(len(s.buf)),
+		Tail:  s.tail,
+		EOF:   s.err == io.EOF,
+		Lines: s.lines,
+	}
+}
+
+// RestoreState restores the given state, obtained earlier from State().
+// The Scanner continues to read from its current input, which must be the
+// same as it was when the state was obtained, and it must have the same
+// Options. Buffered data is not part of the state, it is read again.
+func (s *Scanner) RestoreState(st ScannerState) {
+	starts := s.starts
+	s.Reset(s.r, int(st.Pos))
+	if st.EOF && s.err == nil {
+		s.err = io.EOF
+	}
+	s.restoreParts(starts)
+	// The kept terminator is needed in buf:
+	for st.Tail > 0 && len(s.buf) < st.Tail && s.err == nil {
+		s.readMore()
+	}
+	if s.err == nil {
+		s.tail = st.Tail
+	}
+	s.lines = st.Lines
+}
+
+// Clone returns an independent copy of the Scanner: it reads from the same
+// input, it has the same Options and it continues from the same position, but
+// it has its own copy of the internal buffers, so scanning with one does not
+// affect the other. This can be used for lookahead: scan ahead with the clone,
+// and continue with the original.
+//
+// Options are shared, so if Hash is set, both write to it. If Prefetch is
+// set, the clone starts its own prefetching when it reads.
+func (s *Scanner) Clone() *Scanner {
+	c := *s
+	c.pf = nil
+	if s.arr != nil && s.mem == nil {
+		// Also copy the consumed data after buf, needed to unread the last line:
+		data := s.arr[len(s.arr)-cap(s.buf):]
+		c.arr = c.alloc(len(s.arr))
+		start := len(c.arr) - len(data)
+		copy(c.arr[start:], data)
+		c.buf = c.arr[start : start+len(s.buf)]
+	}
+	c.dec = append([]byte(nil), s.dec...)
+	c.tbuf = append([]byte(nil), s.tbuf...)
+	c.xbuf = append([]byte(nil), s.xbuf...)
+	c.term = append([]byte(nil), s.term...)
+	c.nterm = append([]byte(nil), s.nterm...)
+	c.cr = append([]byte(nil), s.cr...)
+	c.prev = append([]byte(nil), s.prev...)
+	c.lprev = append([]byte(nil), s.lprev...)
+	c.tterm = append([]byte(nil), s.tterm...)
+	c.token = append([]byte(nil), s.token...)
+	c.starts = append([]int64(nil), s.starts...)
+	return &c
+}
+
+// Mark is a position of a Scanner saved by Scanner.Mark(), to which the
+// Scanner can be rewound using Scanner.Rewind().
+type Mark struct {
+	gen     int    // gen is the gen of the Scanner
+	end     int64  // end is the position up to which the input is yet to be scanned
+	tail    int    // tail is the length of the kept terminator
+	lines   int    // lines is the number of lines returned
+	eof     bool   // eof tells if the start of the input has been reached
+	part    int    // part is the index of the part being scanned
+	lpos    int64  // lpos is the position of the last returned line
+	lineEnd int64  // lineEnd is the end position of the last returned line
+	term    []byte // term is the terminator after the last returned line
+	nterm   []byte // nterm is the terminator before the last returned line
+	blank   bool   // blank tells if the last returned line was empty
+	prev    []byte // prev is a copy of the last returned line if Unique is set
+	hasPrev bool   // hasPrev tells if prev is valid
+}
+
+// Mark returns the current position of the Scanner, to which it can be
+// rewound later using Rewind(), e.g. to backtrack in a parser.
+//
+// Unlike State(), a Mark also refers to the buffered data, so rewinding does
+// not read the input again. Making a Mark is cheap, it only copies the last
+// line terminators (and the last line if Unique is set).
+func (s *Scanner) Mark() Mark {
+	m := Mark{
+		gen:     s.gen,
+		end:     s.pos + int64(len(s.buf)),
+		tail:    s.tail,
+		lines:   s.lines,
+		eof:     s.err == io.EOF,
+		part:    s.part,
+		lpos:    s.lpos,
+		lineEnd: s.end,
+		term:    append([]byte(nil), s.term...),
+		nterm:   append([]byte(nil), s.nterm...),
+		blank:   s.blank,
+		hasPrev: s.hasPrev,
+	}
+	if s.o.Unique {
+		m.prev = append([]byte(nil), s.prev...)
+	}
+	return m
+}
+
+// Rewind rewinds the Scanner to the given Mark, obtained earlier from Mark(),
+// so the lines returned since then are returned again. Rewinding to a Mark
+// made after another one is also allowed, as long as its data is buffered.
+//
+// A Mark is only valid as long as the data after it is buffered: reading
+// more data may discard the data of returned lines (e.g. when the buffer is
+// moved or shrunk), and setting the input (e.g. with Reset()) invalidates all
+// marks. If the Mark is no longer valid, ErrInvalidMark is returned, and the
+// Scanner is not changed. Marks of in-memory inputs (see NewBytes()) are valid
+// until the input is set.
+//
+// Statistics (Stats(), LengthStats()) and Hash are not rewound: lines returned
+// again are counted (and written to Hash) again.
+func (s *Scanner) Rewind(m Mark) error {
+	if m.gen != s.gen || m.end < s.pos || m.end > s.pos+int64(cap(s.buf)) {
+		return ErrInvalidMark
+	}
+	s.buf = s.buf[:m.end-s.pos]
+	s.tail, s.clean = m.tail, 0
+	s.lines = m.lines
+	s.err = s.oerr
+	if m.eof && s.err == nil {
+		s.err = io.EOF
+	}
+	if s.part = m.part; s.starts != nil {
+		s.o.MinPos = int(s.starts[s.part])
+	}
+	s.lpos, s.end = m.lpos, m.lineEnd
+	s.term = append(s.term[:0], m.term...)
+	s.nterm = append(s.nterm[:0], m.nterm...)
+	s.cr = s.cr[:0]
+	s.blank = m.blank
+	s.prev, s.hasPrev = append(s.prev[:0], m.prev...), m.hasPrev
+	s.canUnread, s.counted = false, false
+	s.token = nil
+	s.chunk = 0
+	return nil
+}
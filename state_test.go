@@ -0,0 +1,197 @@
+package backscanner
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/icza/mighty"
+)
+
+func TestState(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\n\nLine2\r\nLine3\n"
+	for _, keep := range []bool{false, true} {
+		for n := 0; n <= 6; n++ {
+			o := &Options{ChunkSize: 3, KeepTerminator: keep}
+			scanner := NewOptions(strings.NewReader(input), len(input), o)
+			for i := 0; i < n; i++ {
+				scanner.Line()
+			}
+
+			// Round-trip the state as if sent in a cursor token:
+			data, err := json.Marshal(scanner.State())
+			eq(nil, err)
+			var st ScannerState
+			eq(nil, json.Unmarshal(data, &st))
+
+			restored := NewOptions(strings.NewReader(input), 0, o)
+			restored.RestoreState(st)
+			for {
+				line, pos, err := scanner.Line()
+				line2, pos2, err2 := restored.Line()
+				eq(line, line2)
+				eq(pos, pos2)
+				eq(err, err2)
+				eq(scanner.LineNumber(), restored.LineNumber())
+				if err != nil {
+					eq(io.EOF, err)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	input := "Line1\nLine2\nLine3\nLine4"
+	for _, chunkSize := range []int{1, 3, 100} {
+		scanner := NewString(input, &Options{ChunkSize: chunkSize})
+		line, _, err := scanner.LineBytes()
+		eq("Line4", string(line))
+		eq(nil, err)
+
+		clone := scanner.Clone()
+		// Scanning with the clone must not affect the original and its last line:
+		for _, exp := range []string{"Line3", "Line2", "Line1"} {
+			line2, _, err := clone.Line()
+			eq(exp, line2)
+			eq(nil, err)
+		}
+		_, _, err = clone.Line()
+		eq(io.EOF, err)
+		eq("Line4", string(line))
+
+		eq(nil, scanner.UnreadLine())
+		for _, exp := range []string{"Line4", "Line3"} {
+			line2, _, err := scanner.Line()
+			eq(exp, line2)
+			eq(nil, err)
+		}
+		eq(4, clone.LineNumber())
+		eq(2, scanner.LineNumber())
+
+		// The clone can also unread the line that was last when cloning:
+		clone = scanner.Clone()
+		eq(nil, clone.UnreadLine())
+		line2, pos, err := clone.Line()
+		eq("Line3", line2)
+		eq(12, pos)
+		eq(nil, err)
+	}
+
+	// The state of Unique is not shared:
+	scanner := NewString("x\nb\nb\na\na\n", &Options{Unique: true})
+	for i := 0; i < 2; i++ {
+		_, _, err := scanner.Line()
+		eq(nil, err)
+	}
+	clone := scanner.Clone()
+	var err error
+	for {
+		if _, _, err = scanner.Line(); err != nil {
+			break
+		}
+	}
+	eq(io.EOF, err)
+	for _, exp := range []struct {
+		line string
+		pos  int
+	}{{"b", 4}, {"x", 0}} {
+		line, pos, err := clone.Line()
+		eq(exp.line, line)
+		eq(exp.pos, pos)
+		eq(nil, err)
+	}
+}
+
+func TestMarkRewind(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	input := "Line1\nLine2\nLine2\nLine4\nLine5"
+	type result struct {
+		line string
+		pos  int
+	}
+	// scan returns the next n results:
+	scan := func(scanner *Scanner, n int) (results []result) {
+		for i := 0; i < n; i++ {
+			line, pos, err := scanner.Line()
+			if err != nil {
+				eq(io.EOF, err)
+				break
+			}
+			results = append(results, result{line, pos})
+		}
+		return
+	}
+
+	options := []Options{{}, {KeepTerminator: true}, {Unique: true}}
+	for _, o := range options {
+		for chunkSize := 1; chunkSize <= 8; chunkSize++ {
+			o.ChunkSize = chunkSize
+			for _, inMemory := range []bool{false, true} {
+				var scanner *Scanner
+				if inMemory {
+					scanner = NewBytes([]byte(input), &o)
+				} else {
+					scanner = NewString(input, &o)
+				}
+				start := scanner.Mark()
+				scan(scanner, 1)
+				m := scanner.Mark()
+				exps := scan(scanner, 2)
+				lines := scanner.LineNumber()
+				m2 := scanner.Mark()
+				rest := scan(scanner, 10)
+
+				err := scanner.Rewind(m2)
+				if inMemory {
+					eq(nil, err)
+				}
+				if err == nil {
+					eq(lines, scanner.LineNumber())
+					deq(rest, scan(scanner, 10))
+				} else {
+					eq(ErrInvalidMark, err)
+				}
+
+				err = scanner.Rewind(m)
+				if inMemory {
+					eq(nil, err)
+				}
+				if err == nil {
+					deq(exps, scan(scanner, 2))
+					eq(lines, scanner.LineNumber())
+					// A later Mark is also valid:
+					eq(nil, scanner.Rewind(m2))
+					deq(rest, scan(scanner, 10))
+				} else {
+					eq(ErrInvalidMark, err)
+				}
+
+				if inMemory {
+					eq(nil, scanner.Rewind(start))
+					deq(append(append(scan(NewBytes([]byte(input), &o), 1), exps...), rest...), scan(scanner, 10))
+				}
+
+				// Marks are invalidated by setting the input:
+				scanner.Reset(strings.NewReader(input), len(input))
+				eq(ErrInvalidMark, scanner.Rewind(m))
+				eq(ErrInvalidMark, scanner.Rewind(start))
+			}
+		}
+	}
+
+	// With a large enough buffer, marks stay valid:
+	scanner := NewString(input, &Options{ChunkSize: 4, InitialBufferSize: 64})
+	scan(scanner, 1)
+	m := scanner.Mark()
+	deq([]result{{"Line4", 18}, {"Line2", 12}, {"Line2", 6}, {"Line1", 0}}, scan(scanner, 10))
+	eq(nil, scanner.Rewind(m))
+	deq([]result{{"Line4", 18}, {"Line2", 12}}, scan(scanner, 2))
+}
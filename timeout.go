@@ -0,0 +1,55 @@
+package backscanner
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrReadTimeout indicates that a ReadAt call did not complete within
+// Options.ReadTimeout.
+var ErrReadTimeout = errors.New("read timeout")
+
+// timeoutReaderAt is an io.ReaderAt that gives up ReadAt calls of r not
+// completing within timeout.
+type timeoutReaderAt struct {
+	r       io.ReaderAt
+	timeout time.Duration
+}
+
+// readAtRes is the result of a ReadAt call.
+type readAtRes struct {
+	n   int
+	err error
+}
+
+// ReadAt implements io.ReaderAt. The ReadAt call of r is made in a new
+// goroutine, which is left running if it times out (a ReadAt call cannot be
+// cancelled). Since it may write its buffer even after a time out, it reads
+// into a new buffer which is copied into p.
+func (tr timeoutReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	buf := make([]byte, len(p))
+	ch := make(chan readAtRes, 1)
+	go func() {
+		n, err := tr.r.ReadAt(buf, off)
+		ch <- readAtRes{n, err}
+	}()
+
+	timer := time.NewTimer(tr.timeout)
+	defer timer.Stop()
+	select {
+	case res := <-ch:
+		return copy(p, buf[:res.n]), res.err
+	case <-timer.C:
+		return 0, ErrReadTimeout
+	}
+}
+
+// input returns the input to read from: r wrapped in a timeoutReaderAt if
+// ReadTimeout is set.
+func (s *Scanner) input() io.ReaderAt {
+	if s.o.ReadTimeout > 0 && s.r != nil {
+		return timeoutReaderAt{s.r, s.o.ReadTimeout}
+	}
+	return s.r
+}
@@ -0,0 +1,77 @@
+package backscanner
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/icza/mighty"
+)
+
+// blockingReaderAt blocks the ReadAt calls numbered in block until release
+// is closed.
+type blockingReaderAt struct {
+	r       io.ReaderAt
+	block   map[int]bool
+	release chan struct{}
+
+	mu    sync.Mutex // mu protects calls
+	calls int
+}
+
+func (r *blockingReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	r.mu.Lock()
+	r.calls++
+	block := r.block[r.calls]
+	r.mu.Unlock()
+	if block {
+		<-r.release
+	}
+	return r.r.ReadAt(p, off)
+}
+
+func TestReadTimeout(t *testing.T) {
+	eq, deq := mighty.EqDeq(t)
+
+	input := "Line1\nLine2\nLine3"
+
+	// Reads completing in time:
+	scanner := NewString(input, &Options{ChunkSize: 4, ReadTimeout: time.Minute})
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	eq(nil, scanner.Err())
+	deq([]string{"Line3", "Line2", "Line1"}, lines)
+
+	// The 2nd read times out:
+	r := &blockingReaderAt{r: strings.NewReader(input), block: map[int]bool{2: true}, release: make(chan struct{})}
+	scanner = NewOptions(r, len(input), &Options{ChunkSize: 4, ReadTimeout: time.Millisecond})
+	_, _, err := scanner.Line()
+	deq(&ReadError{Pos: 9, Err: ErrReadTimeout}, err)
+	eq(true, errors.Is(err, ErrReadTimeout))
+	close(r.release)
+
+	// Timed out reads may be retried:
+	r = &blockingReaderAt{r: strings.NewReader(input), block: map[int]bool{2: true}, release: make(chan struct{})}
+	defer close(r.release)
+	var attempts []int
+	scanner = NewOptions(r, len(input), &Options{
+		ChunkSize:   4,
+		ReadTimeout: time.Millisecond,
+		ReadRetry: func(err error, attempt int) bool {
+			eq(ErrReadTimeout, err)
+			attempts = append(attempts, attempt)
+			return true
+		},
+	})
+	for _, exp := range []string{"Line3", "Line2", "Line1"} {
+		line, _, err := scanner.Line()
+		eq(exp, line)
+		eq(nil, err)
+	}
+	deq([]int{1}, attempts)
+}
@@ -0,0 +1,45 @@
+//go:build go1.21
+// +build go1.21
+
+package backscanner
+
+// TypedScanner is a Scanner that decodes lines into values of type T.
+//
+// TypedScanner requires Go 1.21 or newer: only those allow generic code in
+// this module, which supports older Go versions.
+type TypedScanner[T any] struct {
+	s      *Scanner
+	decode func(line []byte) (T, error)
+}
+
+// NewTyped returns a new TypedScanner that scans lines using s, and decodes
+// them using decode. decode is called with the bytes of the lines, which share
+// data with the internal buffer of s (just like those returned by
+// Scanner.LineBytes()), so the decoded value must not retain it.
+func NewTyped[T any](s *Scanner, decode func(line []byte) (T, error)) *TypedScanner[T] {
+	return &TypedScanner[T]{s: s, decode: decode}
+}
+
+// Scanner returns the Scanner used to scan lines.
+func (ts *TypedScanner[T]) Scanner() *Scanner {
+	return ts.s
+}
+
+// Next returns the value decoded from the next line, and the absolute
+// byte-position of the line. After the last line, subsequent calls report
+// io.EOF.
+//
+// If decode fails, its error is returned along with the position of the
+// line. Decode errors do not stop scanning, Next may be called again to
+// continue with the next line.
+func (ts *TypedScanner[T]) Next() (v T, pos int, err error) {
+	line, pos, err := ts.s.LineBytes()
+	if err != nil {
+		return v, 0, err
+	}
+	if v, err = ts.decode(line); err != nil {
+		var zero T
+		return zero, pos, err
+	}
+	return v, pos, nil
+}
@@ -0,0 +1,60 @@
+//go:build go1.21
+// +build go1.21
+
+package backscanner
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/icza/mighty"
+)
+
+func TestTypedScanner(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		v   int
+		pos int
+	}
+
+	scanner := NewString("1\n2\nx\n4", nil)
+	ts := NewTyped(scanner, func(line []byte) (int, error) { return strconv.Atoi(string(line)) })
+	eq(scanner, ts.Scanner())
+	for _, exp := range []result{{4, 6}, {0, 4}, {2, 2}, {1, 0}} {
+		v, pos, err := ts.Next()
+		eq(exp, result{v, pos})
+		if pos == 4 {
+			// Decode errors are reported per line:
+			eq(true, errors.Is(err, strconv.ErrSyntax))
+		} else {
+			eq(nil, err)
+		}
+	}
+	_, _, err := ts.Next()
+	eq(io.EOF, err)
+
+	// Decoding JSON records:
+	type event struct {
+		ID  int    `json:"id"`
+		Msg string `json:"msg"`
+	}
+	decode := func(line []byte) (e event, err error) {
+		err = json.Unmarshal(line, &e)
+		return
+	}
+	es := NewTyped(NewString(`{"id":1,"msg":"start"}`+"\n"+`{"id":2,"msg":"stop"}`, nil), decode)
+	e, pos, err := es.Next()
+	eq(event{2, "stop"}, e)
+	eq(23, pos)
+	eq(nil, err)
+	e, pos, err = es.Next()
+	eq(event{1, "start"}, e)
+	eq(0, pos)
+	eq(nil, err)
+	_, _, err = es.Next()
+	eq(io.EOF, err)
+}
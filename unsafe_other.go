@@ -0,0 +1,21 @@
+//go:build !go1.21
+// +build !go1.21
+
+package backscanner
+
+// LineUnsafe is like Line(), but the returned line is not a copy: it's a
+// string that shares data with the internal buffer of the Scanner, just like
+// the slice returned by LineBytes(), so getting it does not allocate.
+//
+// WARNING: the content of the returned string is only valid until the next
+// call to a method of the Scanner that reads lines (e.g. LineBytes(), Line()
+// or LineUnsafe()): it may be overwritten, which breaks the immutability of
+// strings. The line must not be retained (e.g. stored in a map), use Line()
+// or make a copy if it's needed later.
+//
+// Before Go 1.21, the line is copied, just like by Line(): unsafe.String() was
+// added in Go 1.20, but only Go 1.21 allows using it in a module requiring an
+// older Go version (by this file's build constraint).
+func (s *Scanner) LineUnsafe() (line string, pos int, err error) {
+	return s.Line()
+}
@@ -0,0 +1,43 @@
+//go:build go1.21
+// +build go1.21
+
+package backscanner
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/icza/mighty"
+)
+
+func TestLineUnsafe(t *testing.T) {
+	eq := mighty.Eq(t)
+
+	type result struct {
+		line string
+		pos  int
+	}
+
+	input := "Line1\n\nLine3"
+	scanner := NewString(input, &Options{ChunkSize: 2})
+	for _, exp := range []result{{"Line3", 7}, {"", 6}, {"Line1", 0}} {
+		line, pos, err := scanner.LineUnsafe()
+		eq(exp, result{line, pos})
+		eq(nil, err)
+	}
+	line, _, err := scanner.LineUnsafe()
+	eq("", line)
+	eq(io.EOF, err)
+
+	// Getting the line does not allocate:
+	input = strings.Repeat("Line\n", 100)
+	scanner = NewString(input, nil)
+	scanner.LineUnsafe() // Allocates the buffer
+	allocs := testing.AllocsPerRun(50, func() {
+		if line, _, _ := scanner.LineUnsafe(); line != "Line" {
+			t.Errorf("Expected: Line, got: %s", line)
+		}
+	})
+	eq(0.0, allocs)
+}